@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mistakenelf/fm/internal/frecency"
+)
+
+var jumpCmd = &cobra.Command{
+	Use:   "jump <query>",
+	Short: "Print the best matching visited directory",
+	Long: `Print the most frecent visited directory matching the query, so that a shell
+function can cd into it. Directories are recorded as they are visited in FM,
+or with the --add flag from a shell hook.`,
+	Args: cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		add, err := cmd.Flags().GetString("add")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if add != "" {
+			directory, err := filepath.Abs(add)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if err := frecency.Record(directory); err != nil {
+				log.Fatal(err)
+			}
+
+			return
+		}
+
+		workingDirectory, err := os.Getwd()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		directory, err := frecency.Find(strings.Join(args, " "), workingDirectory)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		fmt.Println(directory)
+	},
+}
@@ -4,13 +4,19 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
 
 	"github.com/mistakenelf/fm/filesystem"
+	"github.com/mistakenelf/fm/internal/bookmarks"
+	"github.com/mistakenelf/fm/internal/journal"
 	"github.com/mistakenelf/fm/internal/theme"
 	"github.com/mistakenelf/fm/internal/tui"
+	"github.com/mistakenelf/fm/internal/views"
 )
 
 var rootCmd = &cobra.Command{
@@ -24,6 +30,11 @@ var rootCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
+		startDir, err = bookmarks.Resolve(startDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+
 		selectionPath, err := cmd.Flags().GetString("selection-path")
 		if err != nil {
 			log.Fatal(err)
@@ -54,6 +65,42 @@ var rootCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
+		layout, err := cmd.Flags().GetString("layout")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if !slices.Contains(tui.Layouts, layout) {
+			log.Fatalf("invalid layout %q, expected one of %s", layout, strings.Join(tui.Layouts, ", "))
+		}
+
+		hidePatterns, err := cmd.Flags().GetStringSlice("hide")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, pattern := range hidePatterns {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				log.Fatalf("invalid hide pattern %q: %v", pattern, err)
+			}
+		}
+
+		confirm, err := cmd.Flags().GetStringSlice("confirm")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, action := range confirm {
+			if !slices.Contains(tui.ConfirmActions, action) {
+				log.Fatalf("invalid confirm action %q, expected one of %s", action, strings.Join(tui.ConfirmActions, ", "))
+			}
+		}
+
+		viewStore, err := views.Load()
+		if err != nil {
+			log.Fatal(err)
+		}
+
 		// If logging is enabled, logs will be output to debug.log.
 		if enableLogging {
 			f, err := tea.LogToFile("debug.log", "debug")
@@ -78,6 +125,11 @@ var rootCmd = &cobra.Command{
 			Theme:          appTheme,
 			ShowIcons:      showIcons,
 			SyntaxTheme:    syntaxTheme,
+			Layout:         layout,
+			HidePatterns:   hidePatterns,
+			Views:          viewStore,
+			Journal:        journal.New(),
+			Confirm:        confirm,
 		}
 
 		m := tui.New(cfg)
@@ -94,14 +146,24 @@ var rootCmd = &cobra.Command{
 // Execute runs the root command and starts the application.
 func Execute() {
 	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(jumpCmd)
+
+	jumpCmd.Flags().String("add", "", "Record a visit to a directory instead of jumping")
 
 	rootCmd.PersistentFlags().String("selection-path", "", "Path to write to file on open.")
-	rootCmd.PersistentFlags().String("start-dir", filesystem.CurrentDirectory, "Starting directory for FM")
+	rootCmd.PersistentFlags().String("start-dir", filesystem.CurrentDirectory, "Starting directory for FM, or @name to start in a bookmark")
 	rootCmd.PersistentFlags().Bool("enable-logging", false, "Enable logging for FM")
 	rootCmd.PersistentFlags().Bool("pretty-markdown", true, "Render markdown to look nice")
 	rootCmd.PersistentFlags().String("theme", "default", "Application theme")
 	rootCmd.PersistentFlags().Bool("show-icons", true, "Show icons")
 	rootCmd.PersistentFlags().String("syntax-theme", "dracula", "Set syntax theme for file output")
+	rootCmd.PersistentFlags().String("layout", tui.DefaultLayout, "Pane layout, either default or miller")
+	rootCmd.PersistentFlags().StringSlice("hide", nil, "Glob patterns of names to always hide, such as __pycache__,*.pyc")
+	rootCmd.PersistentFlags().StringSlice(
+		"confirm",
+		tui.ConfirmActions,
+		"Actions to confirm first, any of trash, delete, overwrite and bulk, or none when empty",
+	)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -76,6 +76,22 @@ func (m *Model) NewStatusMessageCmd(s string) tea.Cmd {
 	}
 }
 
+// SetContentCmd highlights the content provided using the lexer given, which
+// can be the name of a language or a file extension.
+func (m *Model) SetContentCmd(content, lexer string) tea.Cmd {
+	m.Filename = ""
+	syntaxTheme := m.SyntaxTheme
+
+	return func() tea.Msg {
+		highlightedContent, err := Highlight(content, lexer, syntaxTheme)
+		if err != nil {
+			return errorMsg(err.Error())
+		}
+
+		return syntaxMsg(highlightedContent)
+	}
+}
+
 // SetFileName sets current file to highlight.
 func (m *Model) SetFileNameCmd(filename string) tea.Cmd {
 	m.Filename = filename
@@ -0,0 +1,212 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConflictResolution is how an item is handled when something already
+// exists where it is copied, moved or extracted to.
+type ConflictResolution int
+
+const (
+	// ConflictUnresolved fails the operation on the item.
+	ConflictUnresolved ConflictResolution = iota
+	ConflictOverwrite
+	ConflictSkip
+	ConflictKeepBoth
+	ConflictOverwriteIfNewer
+)
+
+// String returns the name of the resolution.
+func (c ConflictResolution) String() string {
+	switch c {
+	case ConflictOverwrite:
+		return "overwrite"
+	case ConflictSkip:
+		return "skip"
+	case ConflictKeepBoth:
+		return "keep both"
+	case ConflictOverwriteIfNewer:
+		return "overwrite if newer"
+	default:
+		return "unresolved"
+	}
+}
+
+// ConflictHandler decides what happens to items whose destination already
+// exists. Every copy, move, rename and extraction goes through one.
+type ConflictHandler struct {
+	// Resolve returns how the conflict at a destination is resolved. Without
+	// it every conflict is unresolved.
+	Resolve func(dst string) ConflictResolution
+	// Remove removes what is at a destination being overwritten. Without it
+	// overwritten items are deleted.
+	Remove func(dst string) error
+}
+
+// Conflict is an item which would be copied, moved or extracted to a
+// destination which already exists.
+type Conflict struct {
+	Destination string
+	Source      fs.FileInfo
+	Existing    fs.FileInfo
+}
+
+// keepBoth resolves every conflict by keeping both items.
+var keepBoth = ConflictHandler{
+	Resolve: func(string) ConflictResolution { return ConflictKeepBoth },
+}
+
+// FindConflict reports whether moving or copying src to dst conflicts with
+// an item at dst. An item moved onto itself, such as when only the case of
+// its name changes, doesn't conflict.
+func FindConflict(src, dst string) (Conflict, bool) {
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return Conflict{}, false
+	}
+
+	dstInfo, err := os.Lstat(dst)
+	if err != nil || os.SameFile(srcInfo, dstInfo) {
+		return Conflict{}, false
+	}
+
+	return Conflict{Destination: dst, Source: srcInfo, Existing: dstInfo}, true
+}
+
+// destination is where an item is written, along with the existing item it
+// replaces once it has been written.
+type destination struct {
+	path     string
+	replaces string
+}
+
+// resolve returns the destination of the item src described by srcInfo,
+// which is dst unless something already exists there. An empty destination
+// is returned for items which are skipped. Items can't overwrite something
+// they are within, and src is empty for items which aren't on disk yet.
+func (h ConflictHandler) resolve(src string, srcInfo fs.FileInfo, dst string) (destination, error) {
+	dstInfo, err := os.Lstat(dst)
+	if errors.Is(err, fs.ErrNotExist) {
+		return destination{path: dst}, nil
+	}
+
+	if err != nil {
+		return destination{}, errors.Unwrap(err)
+	}
+
+	if srcInfo != nil && os.SameFile(srcInfo, dstInfo) {
+		return destination{path: dst}, nil
+	}
+
+	resolution := ConflictUnresolved
+	if h.Resolve != nil {
+		resolution = h.Resolve(dst)
+	}
+
+	switch resolution {
+	case ConflictSkip:
+		return destination{}, nil
+	case ConflictKeepBoth:
+		return destination{path: uniquePath(dst)}, nil
+	case ConflictOverwriteIfNewer:
+		if srcInfo == nil || !srcInfo.ModTime().After(dstInfo.ModTime()) {
+			return destination{}, nil
+		}
+	case ConflictOverwrite:
+	default:
+		return destination{}, fmt.Errorf("%s already exists", dst)
+	}
+
+	if src != "" && isWithin(src, dst) {
+		return destination{}, fmt.Errorf("can't overwrite %s with an item within it", dst)
+	}
+
+	return destination{path: dst, replaces: dst}, nil
+}
+
+// write writes an item to its destination with write, returning the path it
+// ended up at. An item being overwritten is only removed once the new one has
+// been written next to it, so it is kept when writing fails. Should removing
+// it fail, undo takes back what was written.
+func (h ConflictHandler) write(dst destination, write, undo func(path string) error) (string, error) {
+	if dst.replaces == "" {
+		if err := write(dst.path); err != nil {
+			return "", err
+		}
+
+		return dst.path, nil
+	}
+
+	partial := partialPath(dst.path)
+
+	if err := write(partial); err != nil {
+		_ = os.RemoveAll(partial)
+
+		return "", err
+	}
+
+	remove := h.Remove
+	if remove == nil {
+		remove = os.RemoveAll
+	}
+
+	if err := remove(dst.replaces); err != nil {
+		_ = undo(partial)
+
+		return "", errors.Unwrap(err)
+	}
+
+	if err := os.Rename(partial, dst.path); err != nil {
+		return "", errors.Unwrap(err)
+	}
+
+	return dst.path, nil
+}
+
+// isWithin reports whether path is dir or is within it.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+// partialPath returns a hidden path next to the one provided which nothing
+// exists at, for an item to be written to before it replaces another.
+func partialPath(path string) string {
+	dir, name := filepath.Split(path)
+
+	for i := 1; ; i++ {
+		candidate := filepath.Join(dir, fmt.Sprintf(".%s.partial_%d", name, i))
+
+		if _, err := os.Lstat(candidate); errors.Is(err, fs.ErrNotExist) {
+			return candidate
+		}
+	}
+}
+
+// uniquePath returns a path next to the one provided which nothing exists
+// at, numbering the name before its extension.
+func uniquePath(path string) string {
+	dir, name := filepath.Split(path)
+
+	extension := filepath.Ext(name)
+	if extension == name {
+		extension = ""
+	}
+
+	stem := strings.TrimSuffix(name, extension)
+
+	for i := 1; ; i++ {
+		candidate := filepath.Join(dir, fmt.Sprintf("%s_%d%s", stem, i, extension))
+
+		if _, err := os.Lstat(candidate); errors.Is(err, fs.ErrNotExist) {
+			return candidate
+		}
+	}
+}
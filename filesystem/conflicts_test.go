@@ -0,0 +1,170 @@
+package filesystem
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// overwrite resolves every conflict by overwriting, recording the items
+// removed.
+func overwrite(removed *[]string) ConflictHandler {
+	return ConflictHandler{
+		Resolve: func(string) ConflictResolution { return ConflictOverwrite },
+		Remove: func(dst string) error {
+			*removed = append(*removed, dst)
+
+			return os.RemoveAll(dst)
+		},
+	}
+}
+
+// readFile returns the content of a file, failing the test when it can't be
+// read.
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+// assertNoPartials fails the test when an item written before replacing
+// another is left in dir.
+func assertNoPartials(t *testing.T, dir string) {
+	t.Helper()
+
+	partials, err := filepath.Glob(filepath.Join(dir, ".*.partial_*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(partials) != 0 {
+		t.Errorf("partially written items were left: %v", partials)
+	}
+}
+
+func TestOverwrite(t *testing.T) {
+	tests := []struct {
+		name string
+		move func(src, dst string, conflicts ConflictHandler) (string, error)
+	}{
+		{name: "rename", move: RenameDirectoryItem},
+		{name: "move", move: MoveDirectoryItem},
+		{name: "copy", move: CopyDirectoryItem},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, map[string]string{
+				"src/notes.txt": "new",
+				"dst/notes.txt": "old",
+			})
+
+			src := filepath.Join(root, "src", "notes.txt")
+			dst := filepath.Join(root, "dst", "notes.txt")
+
+			var removed []string
+
+			got, err := test.move(src, dst, overwrite(&removed))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != dst {
+				t.Errorf("destination = %s, want %s", got, dst)
+			}
+
+			if content := readFile(t, dst); content != "new" {
+				t.Errorf("content of %s = %q, want %q", dst, content, "new")
+			}
+
+			if len(removed) != 1 || removed[0] != dst {
+				t.Errorf("removed = %v, want [%s]", removed, dst)
+			}
+
+			assertNoPartials(t, filepath.Dir(dst))
+		})
+	}
+}
+
+func TestOverwriteAncestor(t *testing.T) {
+	tests := []struct {
+		name string
+		move func(src, dst string, conflicts ConflictHandler) (string, error)
+	}{
+		{name: "rename", move: RenameDirectoryItem},
+		{name: "move", move: MoveDirectoryItem},
+		{name: "copy", move: CopyDirectoryItem},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, map[string]string{
+				"foo/foo/notes.txt": "content",
+			})
+
+			// Pasting foo/foo into the directory above it lands on foo.
+			src := filepath.Join(root, "foo", "foo")
+			dst := filepath.Join(root, "foo")
+
+			var removed []string
+
+			if _, err := test.move(src, dst, overwrite(&removed)); err == nil {
+				t.Error("overwriting a directory with an item within it succeeded")
+			}
+
+			if len(removed) != 0 {
+				t.Errorf("removed = %v, want nothing", removed)
+			}
+
+			if content := readFile(t, filepath.Join(src, "notes.txt")); content != "content" {
+				t.Errorf("content of the source = %q, want %q", content, "content")
+			}
+		})
+	}
+}
+
+func TestOverwriteFailure(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"src/dir/notes.txt": "new",
+		"dst/dir/notes.txt": "old",
+	})
+
+	// Sockets can't be opened to be copied, so copying the directory fails
+	// part of the way through.
+	listener, err := net.Listen("unix", filepath.Join(root, "src", "dir", "socket"))
+	if err != nil {
+		t.Skip(err)
+	}
+
+	defer func() {
+		_ = listener.Close()
+	}()
+
+	src := filepath.Join(root, "src", "dir")
+	dst := filepath.Join(root, "dst", "dir")
+
+	var removed []string
+
+	if _, err := CopyDirectoryItem(src, dst, overwrite(&removed)); err == nil {
+		t.Fatal("copying a directory with a socket succeeded")
+	}
+
+	if len(removed) != 0 {
+		t.Errorf("removed = %v, want nothing", removed)
+	}
+
+	if content := readFile(t, filepath.Join(dst, "notes.txt")); content != "old" {
+		t.Errorf("content of the existing item = %q, want %q", content, "old")
+	}
+
+	assertNoPartials(t, filepath.Dir(dst))
+}
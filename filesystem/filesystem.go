@@ -4,6 +4,7 @@ package filesystem
 
 import (
 	"archive/zip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -34,11 +36,26 @@ const (
 	FilesListingType       = "files"
 )
 
-// RenameDirectoryItem renames a directory or files given a source and destination.
-func RenameDirectoryItem(src, dst string) error {
-	err := os.Rename(src, dst)
+// RenameDirectoryItem renames a directory or files given a source and
+// destination, returning the destination it ended up at. Conflicts with an
+// existing destination are resolved by the handler provided, and nothing is
+// returned when the item is skipped.
+func RenameDirectoryItem(src, dst string, conflicts ConflictHandler) (string, error) {
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return "", errors.Unwrap(err)
+	}
 
-	return errors.Unwrap(err)
+	target, err := conflicts.resolve(src, srcInfo, dst)
+	if err != nil || target.path == "" {
+		return "", err
+	}
+
+	return conflicts.write(target, func(path string) error {
+		return errors.Unwrap(os.Rename(src, path))
+	}, func(path string) error {
+		return os.Rename(path, src)
+	})
 }
 
 // CreateDirectory creates a new directory given a name.
@@ -130,6 +147,62 @@ func GetHomeDirectory() (string, error) {
 	return home, nil
 }
 
+// GetDataHome returns the base directory user data is kept in, following the
+// XDG base directory specification.
+func GetDataHome() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+
+	if dataHome == "" || !filepath.IsAbs(dataHome) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+
+	return dataHome, nil
+}
+
+// GetDataDirectory returns the directory fm keeps its data in.
+func GetDataDirectory() (string, error) {
+	dataHome, err := GetDataHome()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dataHome, "fm"), nil
+}
+
+// WriteFileAtomically writes data to a file by writing a temporary file next
+// to it and renaming it into place, creating the parent directory if needed.
+func WriteFileAtomically(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return errors.Unwrap(err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Unwrap(err)
+	}
+
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+
+		return errors.Unwrap(err)
+	}
+
+	if err := file.Close(); err != nil {
+		return errors.Unwrap(err)
+	}
+
+	return errors.Unwrap(os.Rename(file.Name(), path))
+}
+
 // GetWorkingDirectory returns the current working directory.
 func GetWorkingDirectory() (string, error) {
 	workingDir, err := os.Getwd()
@@ -147,11 +220,49 @@ func DeleteFile(name string) error {
 	return errors.Unwrap(err)
 }
 
-// MoveDirectoryItem moves a file from one place to another.
-func MoveDirectoryItem(src, dst string) error {
-	err := os.Rename(src, dst)
+// MoveDirectoryItem moves a file from one place to another like
+// RenameDirectoryItem. Items can't be renamed onto another device, so they
+// are copied there and then removed.
+func MoveDirectoryItem(src, dst string, conflicts ConflictHandler) (string, error) {
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return "", errors.Unwrap(err)
+	}
 
-	return errors.Unwrap(err)
+	target, err := conflicts.resolve(src, srcInfo, dst)
+	if err != nil || target.path == "" {
+		return "", err
+	}
+
+	copied := false
+
+	dst, err = conflicts.write(target, func(path string) error {
+		err := os.Rename(src, path)
+		if errors.Is(err, syscall.EXDEV) {
+			copied = true
+
+			return copyDirectoryItem(srcInfo, src, path)
+		}
+
+		return errors.Unwrap(err)
+	}, func(path string) error {
+		if copied {
+			return os.RemoveAll(path)
+		}
+
+		return os.Rename(path, src)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if copied {
+		if err := os.RemoveAll(src); err != nil {
+			return "", errors.Unwrap(err)
+		}
+	}
+
+	return dst, nil
 }
 
 // ReadFileContent returns the contents of a file given a name.
@@ -315,107 +426,188 @@ func Zip(name string) error {
 	return errors.Unwrap(err)
 }
 
-// Unzip unzips a directory given a name.
-func Unzip(name string) error {
-	var output string
+// UnzipDestination returns the path of the directory an archive is unzipped
+// to, which is next to the archive and named after it without its extension.
+func UnzipDestination(path string) string {
+	name := filepath.Base(path)
+
+	if stem := strings.TrimSuffix(name, filepath.Ext(name)); stem != "" {
+		name = stem
+	}
+
+	return filepath.Join(filepath.Dir(path), name)
+}
+
+// Unzip unzips a directory given a name, returning the path of the directory
+// it was extracted to. Conflicts with files which already exist there are
+// resolved by the handler provided.
+func Unzip(name string, conflicts ConflictHandler) (string, error) {
+	output := UnzipDestination(name)
 
 	reader, err := zip.OpenReader(name)
 	if err != nil {
-		return errors.Unwrap(err)
+		return "", errors.Unwrap(err)
 	}
 
 	defer func() {
 		err = reader.Close()
 	}()
 
-	if strings.HasPrefix(name, ".") {
-		output = strings.Split(name, ".")[1]
-	} else {
-		output = strings.Split(name, ".")[0]
-	}
-
 	for _, file := range reader.File {
 		archiveFile := file.Name
 		fpath := filepath.Join(output, archiveFile)
 
 		if !strings.HasPrefix(fpath, filepath.Clean(output)+string(os.PathSeparator)) {
-			return errors.Unwrap(err)
+			return "", fmt.Errorf("%s would be extracted outside of %s", archiveFile, output)
 		}
 
 		if file.FileInfo().IsDir() {
 			err = os.MkdirAll(fpath, os.ModePerm)
 			if err != nil {
-				return errors.Unwrap(err)
+				return "", errors.Unwrap(err)
 			}
 
 			continue
 		}
 
 		if err = os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
-			return errors.Unwrap(err)
+			return "", errors.Unwrap(err)
 		}
 
-		outFile, err := os.OpenFile(filepath.Clean(fpath), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.Mode())
+		target, err := conflicts.resolve("", file.FileInfo(), fpath)
 		if err != nil {
-			return errors.Unwrap(err)
+			return "", err
 		}
 
-		outputFile, err := file.Open()
-		if err != nil {
-			return errors.Unwrap(err)
+		if target.path == "" {
+			continue
 		}
 
-		_, err = io.Copy(outFile, outputFile)
+		_, err = conflicts.write(target, func(path string) error {
+			return extractFile(file, path)
+		}, os.RemoveAll)
 		if err != nil {
-			return errors.Unwrap(err)
+			return "", err
 		}
+	}
 
-		err = outFile.Close()
-		if err != nil {
-			return errors.Unwrap(err)
-		}
+	return output, errors.Unwrap(err)
+}
 
-		err = outputFile.Close()
-		if err != nil {
-			return errors.Unwrap(err)
-		}
+// extractFile writes a file of an archive to path.
+func extractFile(file *zip.File, path string) error {
+	outFile, err := os.OpenFile(filepath.Clean(path), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.Mode())
+	if err != nil {
+		return errors.Unwrap(err)
 	}
 
-	return errors.Unwrap(err)
-}
+	outputFile, err := file.Open()
+	if err != nil {
+		_ = outFile.Close()
 
-// CopyFile copies a file given a name.
-func CopyFile(name string) error {
-	var splitName []string
-	var output string
+		return errors.Unwrap(err)
+	}
 
-	srcFile, err := os.Open(filepath.Clean(name))
+	_, err = io.Copy(outFile, outputFile)
 	if err != nil {
+		_ = outFile.Close()
+		_ = outputFile.Close()
+
 		return errors.Unwrap(err)
 	}
 
+	err = outFile.Close()
+	if err != nil {
+		_ = outputFile.Close()
+
+		return errors.Unwrap(err)
+	}
+
+	return errors.Unwrap(outputFile.Close())
+}
+
+// UnzipConflicts returns the files of an archive which already exist where
+// it would be unzipped to.
+func UnzipConflicts(name string) ([]Conflict, error) {
+	output := UnzipDestination(name)
+
+	reader, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, errors.Unwrap(err)
+	}
+
 	defer func() {
-		err = srcFile.Close()
+		_ = reader.Close()
 	}()
 
+	var conflicts []Conflict
+
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		fpath := filepath.Join(output, file.Name)
+
+		if existing, err := os.Lstat(fpath); err == nil {
+			conflicts = append(conflicts, Conflict{Destination: fpath, Source: file.FileInfo(), Existing: existing})
+		}
+	}
+
+	return conflicts, nil
+}
+
+// copyName returns the name of a copy of a file, which has the current time
+// added to the name of the original.
+func copyName(name string) string {
+	var splitName []string
+
 	fileExtension := filepath.Ext(name)
 	splitFileName := strings.Split(name, "/")
 	fileName := splitFileName[len(splitFileName)-1]
 
 	switch {
 	case strings.HasPrefix(fileName, ".") && fileExtension != "" && fileExtension == fileName:
-		output = fmt.Sprintf("%s_%d", fileName, time.Now().Unix())
+		return fmt.Sprintf("%s_%d", fileName, time.Now().Unix())
 	case strings.HasPrefix(fileName, ".") && fileExtension != "" && fileExtension != fileName:
 		splitName = strings.Split(fileName, ".")
-		output = fmt.Sprintf(".%s_%d.%s", splitName[1], time.Now().Unix(), splitName[2])
+		return fmt.Sprintf(".%s_%d.%s", splitName[1], time.Now().Unix(), splitName[2])
 	case fileExtension != "":
 		splitName = strings.Split(fileName, ".")
-		output = fmt.Sprintf("%s_%d.%s", splitName[0], time.Now().Unix(), splitName[1])
+		return fmt.Sprintf("%s_%d.%s", splitName[0], time.Now().Unix(), splitName[1])
 	default:
-		output = fmt.Sprintf("%s_%d", fileName, time.Now().Unix())
+		return fmt.Sprintf("%s_%d", fileName, time.Now().Unix())
 	}
+}
+
+// CopyPath returns the path of a copy of a file next to the original.
+func CopyPath(name string) string {
+	return filepath.Join(filepath.Dir(name), copyName(name))
+}
+
+// CopyFile copies a file given a name, returning the path of the copy which
+// is next to the original.
+func CopyFile(name string) (string, error) {
+	return CopyDirectoryItem(name, CopyPath(name), keepBoth)
+}
 
-	destFile, err := os.Create(filepath.Clean(output))
+// copyFile copies the content and permissions of a file to dst.
+func copyFile(src, dst string) error {
+	srcFile, err := os.Open(filepath.Clean(src))
+	if err != nil {
+		return errors.Unwrap(err)
+	}
+
+	defer func() {
+		err = srcFile.Close()
+	}()
+
+	info, err := srcFile.Stat()
+	if err != nil {
+		return errors.Unwrap(err)
+	}
+
+	destFile, err := os.OpenFile(filepath.Clean(dst), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
 	if err != nil {
 		return errors.Unwrap(err)
 	}
@@ -437,49 +629,115 @@ func CopyFile(name string) error {
 	return errors.Unwrap(err)
 }
 
-// CopyDirectory copies a directory given a path.
-func CopyDirectory(pathname string) error {
-	name := filepath.Base(pathname)
-	output := fmt.Sprintf("%s_%d", name, time.Now().Unix())
+// CopySymlink copies a symlink given a name, creating a link to the same
+// target next to it so that relative targets still resolve. The path of the
+// copy is returned.
+func CopySymlink(name string) (string, error) {
+	return CopyDirectoryItem(name, CopyPath(name), keepBoth)
+}
 
-	err := filepath.Walk(pathname, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err // Return early if there's an error walking the path
-		}
+// copySymlink creates a link at dst to the target of the symlink src.
+func copySymlink(src, dst string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return errors.Unwrap(err)
+	}
 
-		relPath, err := filepath.Rel(pathname, path)
-		if err != nil {
-			return err // Return if there's an error getting the relative path
-		}
+	err = os.Symlink(target, dst)
 
-		targetPath := filepath.Join(output, relPath)
+	return errors.Unwrap(err)
+}
 
-		if info.IsDir() {
-			return os.Mkdir(targetPath, os.ModePerm)
-		}
+// CreateSymlink creates a symlink with the given name pointing to target.
+func CreateSymlink(target, name string) error {
+	err := os.Symlink(target, name)
 
-		data, err := os.ReadFile(path)
+	return errors.Unwrap(err)
+}
+
+// CreateHardlink creates a hard link with the given name to target.
+func CreateHardlink(target, name string) error {
+	err := os.Link(target, name)
+
+	return errors.Unwrap(err)
+}
+
+// CopyDirectory copies a directory given a path, returning the path of the
+// copy which is next to the original.
+func CopyDirectory(pathname string) (string, error) {
+	output := filepath.Join(filepath.Dir(pathname), fmt.Sprintf("%s_%d", filepath.Base(pathname), time.Now().Unix()))
+
+	return CopyDirectoryItem(pathname, output, keepBoth)
+}
+
+// copyDirectory copies a directory and everything within it to dst. Symlinks
+// within it are copied as links.
+func copyDirectory(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			return err // Return if there's an error reading the file
+			return err
 		}
 
-		err = os.WriteFile(targetPath, data, os.ModePerm)
+		relPath, err := filepath.Rel(src, path)
 		if err != nil {
-			return err // Return if there's an error writing the file
+			return err
 		}
 
-		return nil
+		targetPath := filepath.Join(dst, relPath)
+
+		switch {
+		case info.IsDir():
+			return os.Mkdir(targetPath, info.Mode().Perm()|0o700)
+		case info.Mode()&os.ModeSymlink != 0:
+			return copySymlink(path, targetPath)
+		default:
+			return copyFile(path, targetPath)
+		}
 	})
+}
 
+// CopyDirectoryItem copies a file, directory or symlink to dst, returning
+// the destination it ended up at. Conflicts with an existing destination are
+// resolved by the handler provided, and nothing is returned when the item is
+// skipped.
+func CopyDirectoryItem(src, dst string, conflicts ConflictHandler) (string, error) {
+	info, err := os.Lstat(src)
 	if err != nil {
-		return err // Return the final error, if any
+		return "", errors.Unwrap(err)
 	}
 
-	return nil
+	target, err := conflicts.resolve(src, info, dst)
+	if err != nil || target.path == "" {
+		return "", err
+	}
+
+	return conflicts.write(target, func(path string) error {
+		return copyDirectoryItem(info, src, path)
+	}, os.RemoveAll)
+}
+
+// copyDirectoryItem copies the file, directory or symlink src described by
+// info to dst.
+func copyDirectoryItem(info fs.FileInfo, src, dst string) error {
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return copySymlink(src, dst)
+	case info.IsDir():
+		return copyDirectory(src, dst)
+	default:
+		return copyFile(src, dst)
+	}
 }
 
 // GetDirectoryItemSize calculates the size of a directory or file.
 func GetDirectoryItemSize(path string) (int64, error) {
+	return GetDirectoryItemSizeContext(context.Background(), path)
+}
+
+// GetDirectoryItemSizeContext calculates the size of a directory or file,
+// skipping anything which can't be read. The walk stops early once ctx is
+// cancelled, returning the error of the context.
+func GetDirectoryItemSizeContext(ctx context.Context, path string) (int64, error) {
 	var size int64
 
 	curFile, err := os.Stat(path)
@@ -493,42 +751,90 @@ func GetDirectoryItemSize(path string) (int64, error) {
 
 	err = filepath.WalkDir(path, func(path string, entry os.DirEntry, err error) error {
 		if err != nil {
-			return errors.Unwrap(err)
+			return filepath.SkipDir
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
 		}
 
 		fileInfo, err := entry.Info()
 		if err != nil {
-			return errors.Unwrap(err)
+			return nil
 		}
 
-		if !entry.IsDir() {
-			size += fileInfo.Size()
-		}
+		size += fileInfo.Size()
 
-		return errors.Unwrap(err)
+		return nil
 	})
 
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return 0, ctxErr
+	}
+
 	return size, errors.Unwrap(err)
 }
 
-// FindFilesByName returns files found based on a name.
-func FindFilesByName(name, dir string) ([]string, []fs.DirEntry, error) {
-	var paths []string
-	var entries []fs.DirEntry
-
+// FindFiles walks dir recursively and calls found for every entry accepted by
+// match. Entries excluded by ignore are skipped when it is not nil. The walk
+// stops early once ctx is cancelled.
+func FindFiles(
+	ctx context.Context,
+	dir string,
+	ignore *IgnoreMatcher,
+	match func(path string, entry fs.DirEntry) bool,
+	found func(path string, entry fs.DirEntry),
+) error {
 	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
 		if err != nil {
 			return filepath.SkipDir
 		}
 
-		if strings.Contains(entry.Name(), name) {
-			paths = append(paths, path)
-			entries = append(entries, entry)
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		return errors.Unwrap(err)
+		if ignore != nil && path != dir && ignore.Match(path, entry.IsDir()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if match(path, entry) {
+			found(path, entry)
+		}
+
+		return nil
 	})
 
+	return errors.Unwrap(err)
+}
+
+// FindFilesByName returns files found based on a name, skipping those
+// excluded by ignore files.
+func FindFilesByName(name, dir string) ([]string, []fs.DirEntry, error) {
+	var paths []string
+	var entries []fs.DirEntry
+
+	err := FindFiles(
+		context.Background(),
+		dir,
+		NewIgnoreMatcher(),
+		func(path string, entry fs.DirEntry) bool {
+			return strings.Contains(entry.Name(), name)
+		},
+		func(path string, entry fs.DirEntry) {
+			paths = append(paths, path)
+			entries = append(entries, entry)
+		},
+	)
+
 	return paths, entries, errors.Unwrap(err)
 }
 
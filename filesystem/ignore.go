@@ -0,0 +1,139 @@
+package filesystem
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// ignoreFileNames are the files holding ignore patterns, in increasing order
+// of precedence. Like fd and ripgrep, .gitignore files are only used within a
+// git work tree while the others are used everywhere.
+var ignoreFileNames = [...]string{".gitignore", ".ignore", ".fdignore"}
+
+// ignoreRules are the patterns which apply within a directory, grouped by the
+// file they were read from.
+type ignoreRules struct {
+	patterns [len(ignoreFileNames)][]gitignore.Pattern
+	inRepo   bool
+}
+
+// IgnoreMatcher reports whether paths are excluded by the ignore files of
+// their directory and its parents. The patterns of each directory are read
+// once, so a matcher should not outlive a single listing or search.
+type IgnoreMatcher struct {
+	mu    sync.Mutex
+	rules map[string]*ignoreRules
+}
+
+// NewIgnoreMatcher creates a new matcher for ignore files.
+func NewIgnoreMatcher() *IgnoreMatcher {
+	return &IgnoreMatcher{rules: make(map[string]*ignoreRules)}
+}
+
+// Match reports whether the file or directory at path is ignored. Git
+// directories are always ignored.
+func (m *IgnoreMatcher) Match(path string, isDir bool) bool {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	if isDir && filepath.Base(path) == ".git" {
+		return true
+	}
+
+	m.mu.Lock()
+	rules := m.directoryRules(filepath.Dir(path))
+	m.mu.Unlock()
+
+	var patterns []gitignore.Pattern
+	for _, group := range rules.patterns {
+		patterns = append(patterns, group...)
+	}
+
+	return gitignore.NewMatcher(patterns).Match(splitPath(path), isDir)
+}
+
+// directoryRules returns the rules of a directory, reading the ignore files of
+// it and its parents as needed.
+func (m *IgnoreMatcher) directoryRules(dir string) *ignoreRules {
+	if rules, ok := m.rules[dir]; ok {
+		return rules
+	}
+
+	rules := &ignoreRules{}
+
+	if parent := filepath.Dir(dir); parent != dir {
+		*rules = *m.directoryRules(parent)
+	}
+
+	domain := splitPath(dir)
+
+	// The patterns of a parent repository don't apply within a nested one.
+	if info, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		rules.inRepo = true
+		rules.patterns[0] = nil
+
+		if info.IsDir() {
+			rules.patterns[0] = readIgnoreFile(filepath.Join(dir, ".git", "info", "exclude"), domain)
+		}
+	}
+
+	for i, name := range ignoreFileNames {
+		if i == 0 && !rules.inRepo {
+			continue
+		}
+
+		patterns := readIgnoreFile(filepath.Join(dir, name), domain)
+		if len(patterns) == 0 {
+			continue
+		}
+
+		// Copy so that the patterns of the parent are left untouched.
+		rules.patterns[i] = append(append([]gitignore.Pattern{}, rules.patterns[i]...), patterns...)
+	}
+
+	m.rules[dir] = rules
+
+	return rules
+}
+
+// readIgnoreFile returns the patterns of an ignore file, which are relative
+// to the domain given. Missing or unreadable files have no patterns.
+func readIgnoreFile(path string, domain []string) []gitignore.Pattern {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var patterns []gitignore.Pattern
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		patterns = append(patterns, gitignore.ParsePattern(line, domain))
+	}
+
+	return patterns
+}
+
+// splitPath splits an absolute path into its components.
+func splitPath(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool {
+		return r == filepath.Separator
+	})
+}
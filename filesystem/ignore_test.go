@@ -0,0 +1,95 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTree creates the files provided below root, along with their
+// directories. Names ending in a slash are created as directories.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+
+		if name[len(name)-1] == '/' {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := t.TempDir()
+
+	writeTree(t, root, map[string]string{
+		"repo/.git/info/exclude":            "excluded.txt\n",
+		"repo/.gitignore":                   "# comment\n*.log\n!keep.log\n/anchored.txt\nbuild/\noverride.txt\n",
+		"repo/.ignore":                      "by-ignore.txt\n!override.txt\nfd-keep.txt\n",
+		"repo/.fdignore":                    "!fd-keep.txt\n",
+		"repo/sub/.gitignore":               "!sub.log\nlocal.txt\n",
+		"repo/sub/nested/.git/info/exclude": "nested-excluded.txt\n",
+		"repo/sub/nested/.gitignore":        "nested.txt\n",
+		"repo/module/.git":                  "gitdir: ../.git/modules/module\n",
+		"plain/.gitignore":                  "*.txt\n",
+		"plain/.ignore":                     "*.md\n",
+		"plain/sub/.fdignore":               "/top.cfg\n!readme.md\n",
+	})
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{path: "repo/a.log", want: true},
+		{path: "repo/keep.log", want: false},
+		{path: "repo/sub/b.log", want: true},
+		{path: "repo/sub/sub.log", want: false},
+		{path: "repo/anchored.txt", want: true},
+		{path: "repo/sub/anchored.txt", want: false},
+		{path: "repo/build", isDir: true, want: true},
+		{path: "repo/build", want: false},
+		{path: "repo/sub/build", isDir: true, want: true},
+		{path: "repo/sub/local.txt", want: true},
+		{path: "repo/local.txt", want: false},
+		{path: "repo/excluded.txt", want: true},
+		{path: "repo/sub/excluded.txt", want: true},
+		{path: "repo/by-ignore.txt", want: true},
+		{path: "repo/override.txt", want: false},
+		{path: "repo/fd-keep.txt", want: false},
+		{path: "repo/.git", isDir: true, want: true},
+		{path: "repo/sub/nested/app.log", want: false},
+		{path: "repo/sub/nested/excluded.txt", want: false},
+		{path: "repo/sub/nested/nested.txt", want: true},
+		{path: "repo/sub/nested/by-ignore.txt", want: true},
+		{path: "repo/sub/nested/nested-excluded.txt", want: true},
+		{path: "repo/module/a.log", want: false},
+		{path: "repo/module/by-ignore.txt", want: true},
+		{path: "plain/a.txt", want: false},
+		{path: "plain/a.md", want: true},
+		{path: "plain/sub/top.cfg", want: true},
+		{path: "plain/sub/deeper/top.cfg", want: false},
+		{path: "plain/sub/readme.md", want: false},
+	}
+
+	matcher := NewIgnoreMatcher()
+
+	for _, test := range tests {
+		if got := matcher.Match(filepath.Join(root, filepath.FromSlash(test.path)), test.isDir); got != test.want {
+			t.Errorf("Match(%s, %t) = %t, want %t", test.path, test.isDir, got, test.want)
+		}
+	}
+}
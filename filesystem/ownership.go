@@ -0,0 +1,50 @@
+package filesystem
+
+import (
+	"os"
+	"os/user"
+	"sync"
+)
+
+var (
+	ownerNames     = make(map[string]string)
+	groupNames     = make(map[string]string)
+	ownershipMutex sync.Mutex
+)
+
+// GetOwnership returns the owner and group names of a file, falling back to
+// the numeric ids when they cannot be resolved. Empty strings are returned on
+// platforms without unix style ownership.
+func GetOwnership(info os.FileInfo) (string, string) {
+	uid, gid, ok := getOwnershipIDs(info)
+	if !ok {
+		return "", ""
+	}
+
+	ownershipMutex.Lock()
+	defer ownershipMutex.Unlock()
+
+	owner, ok := ownerNames[uid]
+	if !ok {
+		owner = uid
+
+		if u, err := user.LookupId(uid); err == nil {
+			owner = u.Username
+		}
+
+		ownerNames[uid] = owner
+	}
+
+	group, ok := groupNames[gid]
+	if !ok {
+		group = gid
+
+		if g, err := user.LookupGroupId(gid); err == nil {
+			group = g.Name
+		}
+
+		groupNames[gid] = group
+	}
+
+	return owner, group
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package filesystem
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// getOwnershipIDs returns the user and group ids owning a file.
+func getOwnershipIDs(info os.FileInfo) (string, string, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", "", false
+	}
+
+	return strconv.FormatUint(uint64(stat.Uid), 10), strconv.FormatUint(uint64(stat.Gid), 10), true
+}
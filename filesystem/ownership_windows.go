@@ -0,0 +1,10 @@
+//go:build windows
+
+package filesystem
+
+import "os"
+
+// getOwnershipIDs is not supported on windows.
+func getOwnershipIDs(info os.FileInfo) (string, string, bool) {
+	return "", "", false
+}
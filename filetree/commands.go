@@ -1,8 +1,9 @@
 package filetree
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -12,19 +13,33 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/mistakenelf/fm/filesystem"
+	"github.com/mistakenelf/fm/internal/journal"
+	"github.com/mistakenelf/fm/internal/trash"
+	"github.com/mistakenelf/fm/internal/views"
 )
 
 type errorMsg string
 type copyToClipboardMsg string
 type statusMessageTimeoutMsg struct{}
 type editorFinishedMsg struct{ err error }
-type createFileMsg struct{}
-type createDirectoryMsg struct{}
-type moveDirectoryItemMsg struct{}
-type renameDirectoryItemMsg struct{}
+type createFileMsg struct{ id int }
+type createDirectoryMsg struct{ id int }
+type moveDirectoryItemMsg struct{ id int }
+type renameDirectoryItemMsg struct{ id int }
+type getChildListingMsg struct {
+	id    int
+	files []DirectoryItem
+	path  string
+}
 type getDirectoryListingMsg struct {
+	id               int
 	files            []DirectoryItem
 	workingDirectory string
+	selectedName     string
+	historyIndex     int
+	batches          <-chan directoryBatch
+	cancel           context.CancelFunc
+	view             *views.Settings
 }
 
 // NewStatusMessageCmd sets a new status message, which will show for a limited
@@ -47,191 +62,328 @@ func (m *Model) NewStatusMessageCmd(s string) tea.Cmd {
 // CreateDirectoryCmd creates a directory based on the name provided.
 func (m *Model) CreateDirectoryCmd(name string) tea.Cmd {
 	return func() tea.Msg {
+		_, statErr := os.Lstat(name)
+
 		if err := filesystem.CreateDirectory(name); err != nil {
 			return errorMsg(err.Error())
 		}
 
-		return createDirectoryMsg{}
+		if errors.Is(statErr, os.ErrNotExist) {
+			m.journal.Record("create", journal.Created(name))
+		}
+
+		return createDirectoryMsg{id: m.id}
 	}
 }
 
 // CreateFileCmd creates a file based on the name provided.
 func (m *Model) CreateFileCmd(name string) tea.Cmd {
 	return func() tea.Msg {
+		_, statErr := os.Lstat(name)
+
 		if err := filesystem.CreateFile(name); err != nil {
 			return errorMsg(err.Error())
 		}
 
-		return createFileMsg{}
+		if errors.Is(statErr, os.ErrNotExist) {
+			m.journal.Record("create", journal.Created(name))
+		}
+
+		return createFileMsg{id: m.id}
 	}
 }
 
-// RenameDirectoryItemCmd renames a file or folder given a name.
-func (m *Model) RenameDirectoryItemCmd(originalName, newName string) tea.Cmd {
+// RenameDirectoryItemCmd renames a file or folder given a name, resolving a
+// conflict with an existing item using resolve.
+func (m *Model) RenameDirectoryItemCmd(originalName, newName string, resolve ConflictResolver) tea.Cmd {
 	return func() tea.Msg {
-		if err := filesystem.RenameDirectoryItem(originalName, newName); err != nil {
+		var actions []journal.Action
+		defer func() { m.journal.Record("rename", actions...) }()
+
+		output, err := filesystem.RenameDirectoryItem(originalName, newName, conflictHandler(resolve, &actions))
+		if err != nil {
 			return errorMsg(err.Error())
 		}
 
-		return renameDirectoryItemMsg{}
+		if output != "" {
+			actions = append(actions, journal.Moved(originalName, output))
+		}
+
+		return renameDirectoryItemMsg{id: m.id}
 	}
 }
 
-// MoveDirectoryItemCmd moves an item from one place to another.
-func (m Model) MoveDirectoryItemCmd(source, destination string) tea.Cmd {
+// MoveDirectoryItemCmd moves an item from one place to another, resolving a
+// conflict with an existing item using resolve.
+func (m Model) MoveDirectoryItemCmd(source, destination string, resolve ConflictResolver) tea.Cmd {
 	return func() tea.Msg {
-		if err := filesystem.MoveDirectoryItem(source, destination); err != nil {
+		var actions []journal.Action
+		defer func() { m.journal.Record("move", actions...) }()
+
+		output, err := filesystem.MoveDirectoryItem(source, destination, conflictHandler(resolve, &actions))
+		if err != nil {
 			return errorMsg(err.Error())
 		}
 
-		return moveDirectoryItemMsg{}
+		if output != "" {
+			actions = append(actions, journal.Moved(source, output))
+		}
+
+		return moveDirectoryItemMsg{id: m.id}
 	}
 }
 
-// GetDirectoryListingCmd updates the directory listing based on the name of the directory provided.
-func (m Model) GetDirectoryListingCmd(directoryName string) tea.Cmd {
+// MoveDirectoryItemsCmd moves each of the items provided into the destination
+// directory, or the targets of symlinks when symlinks are followed. Conflicts
+// with items already there are resolved using resolve.
+func (m Model) MoveDirectoryItemsCmd(items []DirectoryItem, destination string, resolve ConflictResolver) tea.Cmd {
+	items = m.operationItems(items)
+
 	return func() tea.Msg {
-		var err error
-		var directoryItems []DirectoryItem
-		var files []fs.DirEntry
-		var directoryPath string
+		var actions []journal.Action
+		defer func() { m.journal.Record("move", actions...) }()
 
-		if directoryName == filesystem.HomeDirectory {
-			directoryName, err = filesystem.GetHomeDirectory()
+		handler := conflictHandler(resolve, &actions)
+
+		for _, item := range items {
+			output, err := filesystem.MoveDirectoryItem(item.Path, filepath.Join(destination, item.Name), handler)
 			if err != nil {
 				return errorMsg(err.Error())
 			}
-		}
 
-		if !filepath.IsAbs(directoryName) {
-			directoryPath, err = filepath.Abs(directoryName)
-			fmt.Println(directoryPath)
-			if err != nil {
-				return errorMsg(err.Error())
+			if output != "" {
+				actions = append(actions, journal.Moved(item.Path, output))
 			}
-		} else {
-			directoryPath = directoryName
 		}
 
-		directoryInfo, err := os.Stat(directoryPath)
-		if err != nil {
+		return moveDirectoryItemMsg{id: m.id}
+	}
+}
+
+// MarkByGlobCmd marks the items matching the glob pattern provided.
+func (m *Model) MarkByGlobCmd(pattern string) tea.Cmd {
+	m.State = IdleState
+
+	count, err := m.MarkByGlob(pattern)
+	if err != nil {
+		return func() tea.Msg {
 			return errorMsg(err.Error())
 		}
+	}
 
-		if !directoryInfo.IsDir() {
-			return nil
-		}
+	return m.NewStatusMessageCmd(fmt.Sprintf("Marked %d items matching %s", count, pattern))
+}
 
-		if !m.showDirectoriesOnly && !m.showFilesOnly {
-			files, err = filesystem.GetDirectoryListing(directoryName, m.showHidden)
-			if err != nil {
-				return errorMsg(err.Error())
-			}
-		} else {
-			listingType := filesystem.DirectoriesListingType
+// GetDirectoryListingCmd updates the directory listing based on the name of the directory provided.
+func (m Model) GetDirectoryListingCmd(directoryName string) tea.Cmd {
+	return m.getDirectoryListingCmd(directoryName, "", noHistoryIndex)
+}
 
-			if m.showFilesOnly {
-				listingType = filesystem.FilesListingType
-			}
+// SelectPathCmd opens the directory containing the path provided and moves
+// the cursor onto it.
+func (m Model) SelectPathCmd(path string) tea.Cmd {
+	return m.getDirectoryListingCmd(filepath.Dir(path), filepath.Base(path), noHistoryIndex)
+}
 
-			files, err = filesystem.GetDirectoryListingByType(directoryName, listingType, m.showHidden)
-			if err != nil {
-				return errorMsg(err.Error())
-			}
+// getDirectoryListingCmd updates the directory listing and places the cursor
+// on the item with the selected name, if one is provided. The history index
+// is set when the listing navigates to an entry of the history. The first
+// batch of items is read right away and the rest are streamed in afterwards,
+// using the view settings remembered for the directory.
+func (m Model) getDirectoryListingCmd(directoryName, selectedName string, historyIndex int) tea.Cmd {
+	return func() tea.Msg {
+		directoryPath, err := resolveDirectory(directoryName)
+		if err != nil {
+			return errorMsg(err.Error())
 		}
 
-		for _, file := range files {
-			fileInfo, err := file.Info()
-			if err != nil {
-				continue
-			}
+		if directoryPath == "" {
+			return nil
+		}
 
-			filePath := filepath.Join(directoryPath, file.Name())
-			isDirectory := fileInfo.IsDir()
+		view := m.directoryViewSettings(directoryPath)
+		if view != nil {
+			m.applyViewSettings(*view)
+		}
 
-			isSymlink := fileInfo.Mode()&os.ModeSymlink != 0
+		ctx, cancel := context.WithCancel(context.Background())
+		batches := make(chan directoryBatch)
 
-			if isSymlink {
-				symlinkPath, _ := filepath.EvalSymlinks(filepath.Join(directoryPath, file.Name()))
-				filePath = symlinkPath
-				symlinkInfo, err := os.Stat(symlinkPath)
-				if err != nil {
-					return errorMsg(err.Error())
-				}
+		go m.readDirectoryBatches(ctx, directoryPath, batches)
 
-				isDirectory = symlinkInfo.IsDir()
-			}
+		batch := <-batches
+		if batch.err != nil {
+			cancel()
 
-			fileSize := filesystem.ConvertBytesToSizeString(fileInfo.Size())
-
-			directoryItems = append(directoryItems, DirectoryItem{
-				Name:        file.Name(),
-				Details:     fileInfo.Mode().String(),
-				Path:        filePath,
-				Extension:   filepath.Ext(fileInfo.Name()),
-				IsDirectory: isDirectory,
-				FileInfo:    fileInfo,
-				FileSize:    fileSize,
-			})
+			return errorMsg(batch.err.Error())
 		}
 
 		return getDirectoryListingMsg{
-			files:            directoryItems,
+			id:               m.id,
+			files:            batch.files,
 			workingDirectory: directoryPath,
+			selectedName:     selectedName,
+			historyIndex:     historyIndex,
+			batches:          batches,
+			cancel:           cancel,
+			view:             view,
 		}
 	}
 }
 
-// deleteDirectoryItemCmd deletes a directory based on the name provided.
-func deleteDirectoryItemCmd(name string, isDirectory bool) tea.Cmd {
+// getChildListingCmd loads the listing of a directory expanded in the tree view.
+func (m Model) getChildListingCmd(directoryName string) tea.Cmd {
 	return func() tea.Msg {
-		if isDirectory {
-			if err := filesystem.DeleteDirectory(name); err != nil {
-				return errorMsg(err.Error())
+		directoryItems, directoryPath, err := m.readDirectory(directoryName)
+		if err != nil {
+			return errorMsg(err.Error())
+		}
+
+		if directoryPath == "" {
+			return nil
+		}
+
+		return getChildListingMsg{
+			id:    m.id,
+			files: directoryItems,
+			path:  directoryName,
+		}
+	}
+}
+
+// readDirectory returns the items within a directory along with its absolute
+// path. An empty path is returned when the name provided is not a directory.
+func (m Model) readDirectory(directoryName string) ([]DirectoryItem, string, error) {
+	directoryPath, err := resolveDirectory(directoryName)
+	if err != nil || directoryPath == "" {
+		return nil, "", err
+	}
+
+	files, err := os.ReadDir(directoryPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	directoryItems := make([]DirectoryItem, 0, len(files))
+	ignore := m.ignoreMatcher()
+
+	for _, file := range files {
+		if !m.includeEntry(ignore, directoryPath, file) {
+			continue
+		}
+
+		directoryItems = append(directoryItems, newDirectoryItem(directoryPath, file))
+	}
+
+	return directoryItems, directoryPath, nil
+}
+
+// deleteDirectoryItemsCmd deletes the directory items provided.
+func deleteDirectoryItemsCmd(items []DirectoryItem) tea.Cmd {
+	return func() tea.Msg {
+		for _, item := range items {
+			if item.IsDirectory {
+				if err := filesystem.DeleteDirectory(item.Path); err != nil {
+					return errorMsg(err.Error())
+				}
+			} else {
+				if err := filesystem.DeleteFile(item.Path); err != nil {
+					return errorMsg(err.Error())
+				}
 			}
-		} else {
-			if err := filesystem.DeleteFile(name); err != nil {
+		}
+
+		return nil
+	}
+}
+
+// trashDirectoryItemsCmd moves the directory items provided to the trash.
+func trashDirectoryItemsCmd(items []DirectoryItem, j *journal.Journal) tea.Cmd {
+	return func() tea.Msg {
+		var actions []journal.Action
+		defer func() { j.Record("trash", actions...) }()
+
+		for _, item := range items {
+			trashItem, err := trash.Trash(item.Path)
+			if err != nil {
 				return errorMsg(err.Error())
 			}
+
+			actions = append(actions, journal.Trashed(trashItem))
 		}
 
 		return nil
 	}
 }
 
-// zipDirectoryCmd zips a directory based on the name provided.
-func zipDirectoryCmd(name string) tea.Cmd {
+// zipDirectoryItemsCmd zips each of the directory items provided. Archives
+// hold the content symlinks point to rather than the links.
+func zipDirectoryItemsCmd(items []DirectoryItem) tea.Cmd {
 	return func() tea.Msg {
-		if err := filesystem.Zip(name); err != nil {
-			return errorMsg(err.Error())
+		for _, item := range items {
+			if err := filesystem.Zip(item.ResolvedPath()); err != nil {
+				return errorMsg(err.Error())
+			}
 		}
 
 		return nil
 	}
 }
 
-// unzipDirectoryCmd unzips a directory based on the name provided.
-func unzipDirectoryCmd(name string) tea.Cmd {
+// unzipDirectoryItemsCmd unzips each of the directory items provided,
+// resolving conflicts with files already extracted using resolve. Only
+// archives extracted to a new directory can be undone, so files overwritten
+// in an existing one are left in the trash without being recorded.
+func unzipDirectoryItemsCmd(items []DirectoryItem, resolve ConflictResolver, j *journal.Journal) tea.Cmd {
 	return func() tea.Msg {
-		if err := filesystem.Unzip(name); err != nil {
-			return errorMsg(err.Error())
+		var actions, overwritten []journal.Action
+		defer func() { j.Record("extract", actions...) }()
+
+		handler := conflictHandler(resolve, &overwritten)
+
+		for _, item := range items {
+			_, statErr := os.Lstat(filesystem.UnzipDestination(item.Path))
+
+			output, err := filesystem.Unzip(item.Path, handler)
+			if err != nil {
+				return errorMsg(err.Error())
+			}
+
+			if errors.Is(statErr, os.ErrNotExist) {
+				actions = append(actions, journal.Created(output))
+			}
 		}
 
 		return nil
 	}
 }
 
-// copyDirectoryItemCmd copies a directory based on the name provided.
-func copyDirectoryItemCmd(name string, isDirectory bool) tea.Cmd {
+// copyDirectoryItemsCmd copies each of the directory items provided.
+func copyDirectoryItemsCmd(items []DirectoryItem, j *journal.Journal) tea.Cmd {
 	return func() tea.Msg {
-		if isDirectory {
-			if err := filesystem.CopyDirectory(name); err != nil {
-				return errorMsg(err.Error())
+		var actions []journal.Action
+		defer func() { j.Record("copy", actions...) }()
+
+		for _, item := range items {
+			var (
+				output string
+				err    error
+			)
+
+			switch {
+			case item.IsSymlink:
+				output, err = filesystem.CopySymlink(item.Path)
+			case item.IsDirectory:
+				output, err = filesystem.CopyDirectory(item.Path)
+			default:
+				output, err = filesystem.CopyFile(item.Path)
 			}
-		} else {
-			if err := filesystem.CopyFile(name); err != nil {
+
+			if err != nil {
 				return errorMsg(err.Error())
 			}
+
+			actions = append(actions, journal.Created(output))
 		}
 
 		return nil
@@ -0,0 +1,30 @@
+package filetree
+
+import (
+	"github.com/mistakenelf/fm/filesystem"
+	"github.com/mistakenelf/fm/internal/journal"
+	"github.com/mistakenelf/fm/internal/trash"
+)
+
+// ConflictResolver returns how the conflict with the item already at a
+// destination is resolved.
+type ConflictResolver func(dst string) filesystem.ConflictResolution
+
+// conflictHandler returns the handler resolving conflicts with resolve.
+// Items being overwritten are moved to the trash rather than deleted, which
+// is added to actions so that it can be undone.
+func conflictHandler(resolve ConflictResolver, actions *[]journal.Action) filesystem.ConflictHandler {
+	return filesystem.ConflictHandler{
+		Resolve: resolve,
+		Remove: func(dst string) error {
+			item, err := trash.Trash(dst)
+			if err != nil {
+				return err
+			}
+
+			*actions = append(*actions, journal.Trashed(item))
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,149 @@
+package filetree
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/mistakenelf/fm/internal/gitrepo"
+)
+
+type gitActionMsg string
+type gitStatusMsg struct {
+	id        int
+	directory string
+	status    *gitrepo.Status
+	err       error
+}
+
+// loadGitStatusCmd reads the git status of the work tree containing the
+// current directory in the background. Only one read runs at a time, a read
+// requested in the meantime runs once it finishes.
+func (m *Model) loadGitStatusCmd() tea.Cmd {
+	if !m.showGitStatus || m.CurrentDirectory == "" {
+		return nil
+	}
+
+	if m.gitStatusLoading {
+		m.gitStatusStale = true
+
+		return nil
+	}
+
+	m.gitStatusLoading = true
+	id := m.id
+	directory := m.CurrentDirectory
+
+	return func() tea.Msg {
+		status, err := gitrepo.ReadStatus(directory)
+
+		return gitStatusMsg{id: id, directory: directory, status: status, err: err}
+	}
+}
+
+// handleGitStatus stores the git status read for a directory and annotates
+// the items of the listing with it.
+func (m *Model) handleGitStatus(msg gitStatusMsg) tea.Cmd {
+	m.gitStatusLoading = false
+
+	if m.gitStatusStale || msg.directory != m.CurrentDirectory {
+		m.gitStatusStale = false
+
+		return m.loadGitStatusCmd()
+	}
+
+	if msg.err != nil {
+		return func() tea.Msg {
+			return errorMsg(msg.err.Error())
+		}
+	}
+
+	m.gitStatus = msg.status
+	m.annotateGitStatus(m.unfilteredFiles)
+
+	for _, children := range m.children {
+		m.annotateGitStatus(children)
+	}
+
+	_ = m.applyFilter()
+
+	return nil
+}
+
+// clearGitStatus forgets the git status when the directory is not within the
+// work tree it was read for.
+func (m *Model) clearGitStatus(directory string) {
+	if m.gitStatus == nil {
+		return
+	}
+
+	relativePath, err := filepath.Rel(m.gitStatus.Root, directory)
+	if err != nil || strings.HasPrefix(relativePath, "..") {
+		m.gitStatus = nil
+	}
+}
+
+// annotateGitStatus sets the git status of each of the items provided.
+func (m Model) annotateGitStatus(files []DirectoryItem) {
+	for i := range files {
+		files[i].GitStatus = gitrepo.Unmodified
+
+		if m.gitStatus != nil {
+			files[i].GitStatus = m.gitStatus.Get(files[i].Path, files[i].IsDirectory)
+		}
+	}
+}
+
+// GetGitBranch returns the branch checked out in the work tree containing the
+// current directory, reporting false when it is not within a work tree.
+func (m Model) GetGitBranch() (gitrepo.Branch, bool) {
+	if m.gitStatus == nil {
+		return gitrepo.Branch{}, false
+	}
+
+	return m.gitStatus.Branch, true
+}
+
+// gitActionCmd runs a git action on each of the items provided.
+func gitActionCmd(items []DirectoryItem, action func(path string) error, done string) tea.Cmd {
+	return func() tea.Msg {
+		for _, item := range items {
+			if err := action(item.Path); err != nil {
+				return errorMsg(err.Error())
+			}
+		}
+
+		count := "items"
+		if len(items) == 1 {
+			count = "item"
+		}
+
+		return gitActionMsg(fmt.Sprintf("%s %d %s", done, len(items), count))
+	}
+}
+
+// StageCmd stages the changes to the selected or marked items.
+func (m *Model) StageCmd() tea.Cmd {
+	return tea.Sequence(
+		gitActionCmd(m.GetSelectedItems(), gitrepo.Stage, "Staged"),
+		m.loadGitStatusCmd(),
+	)
+}
+
+// UnstageCmd unstages the changes to the selected or marked items.
+func (m *Model) UnstageCmd() tea.Cmd {
+	return tea.Sequence(
+		gitActionCmd(m.GetSelectedItems(), gitrepo.Unstage, "Unstaged"),
+		m.loadGitStatusCmd(),
+	)
+}
+
+// DiscardCmd discards the unstaged changes to the selected or marked items.
+func (m *Model) DiscardCmd() tea.Cmd {
+	return tea.Sequence(
+		gitActionCmd(m.GetSelectedItems(), gitrepo.Discard, "Discarded changes to"),
+		m.GetDirectoryListingCmd(m.CurrentDirectory),
+	)
+}
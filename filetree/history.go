@@ -0,0 +1,93 @@
+package filetree
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxHistoryEntries limits how many visited directories are remembered.
+const maxHistoryEntries = 100
+
+// noHistoryIndex is used for listings which are not a history navigation.
+const noHistoryIndex = -1
+
+// historyEntry is a directory visited by the filetree along with the
+// selected item and scroll offset it was left with.
+type historyEntry struct {
+	path     string
+	position viewPosition
+}
+
+// updateHistory saves the view of the directory being left and records the
+// directory being entered. Listings which navigate the history move within
+// it rather than adding a new entry.
+func (m *Model) updateHistory(msg getDirectoryListingMsg) {
+	if m.historyIndex < len(m.history) {
+		m.history[m.historyIndex].position = m.getViewPosition()
+	}
+
+	if msg.historyIndex != noHistoryIndex &&
+		msg.historyIndex < len(m.history) &&
+		m.history[msg.historyIndex].path == msg.workingDirectory {
+		m.historyIndex = msg.historyIndex
+
+		return
+	}
+
+	if len(m.history) > 0 {
+		m.history = m.history[:m.historyIndex+1]
+	}
+
+	m.history = append(m.history, historyEntry{path: msg.workingDirectory})
+
+	if len(m.history) > maxHistoryEntries {
+		m.history = m.history[len(m.history)-maxHistoryEntries:]
+	}
+
+	m.historyIndex = len(m.history) - 1
+}
+
+// historyPosition returns the selected item and scroll offset the current
+// history entry was last left with.
+func (m Model) historyPosition() viewPosition {
+	if m.historyIndex < len(m.history) {
+		return m.history[m.historyIndex].position
+	}
+
+	return viewPosition{}
+}
+
+// GetHistory returns the directories visited by the filetree, oldest first.
+func (m Model) GetHistory() []string {
+	paths := make([]string, 0, len(m.history))
+
+	for _, entry := range m.history {
+		paths = append(paths, entry.path)
+	}
+
+	return paths
+}
+
+// GetHistoryIndex returns the index of the current directory in the history.
+func (m Model) GetHistoryIndex() int {
+	return m.historyIndex
+}
+
+// GoToHistoryCmd returns to the directory at the given index of the history,
+// restoring its cursor and scroll offset.
+func (m Model) GoToHistoryCmd(index int) tea.Cmd {
+	if index < 0 || index >= len(m.history) || index == m.historyIndex {
+		return nil
+	}
+
+	return m.getDirectoryListingCmd(m.history[index].path, "", index)
+}
+
+// GoBackCmd returns to the previously visited directory.
+func (m Model) GoBackCmd() tea.Cmd {
+	return m.GoToHistoryCmd(m.historyIndex - 1)
+}
+
+// GoForwardCmd goes forward to the directory visited after the current one.
+func (m Model) GoForwardCmd() tea.Cmd {
+	return m.GoToHistoryCmd(m.historyIndex + 1)
+}
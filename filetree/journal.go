@@ -0,0 +1,39 @@
+package filetree
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/mistakenelf/fm/internal/journal"
+)
+
+type journalMsg string
+
+// SetJournal sets the journal file operations are recorded in so that they
+// can be undone.
+func (m *Model) SetJournal(j *journal.Journal) {
+	m.journal = j
+}
+
+// UndoCmd reverses the last file operation recorded in the journal.
+func (m Model) UndoCmd() tea.Cmd {
+	return journalCmd(m.journal.Undo, "Undid")
+}
+
+// RedoCmd performs the last undone file operation again.
+func (m Model) RedoCmd() tea.Cmd {
+	return journalCmd(m.journal.Redo, "Redid")
+}
+
+// journalCmd undoes or redoes an operation, reporting which it was.
+func journalCmd(reverse func() (string, error), done string) tea.Cmd {
+	return func() tea.Msg {
+		description, err := reverse()
+		if err != nil {
+			return errorMsg(err.Error())
+		}
+
+		return journalMsg(fmt.Sprintf("%s %s", done, description))
+	}
+}
@@ -0,0 +1,152 @@
+package filetree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/mistakenelf/fm/filesystem"
+	"github.com/mistakenelf/fm/internal/journal"
+)
+
+// operationItems returns the items an operation acts on. Symlinks stand for
+// the links themselves unless symlinks are followed, in which case their
+// targets are acted on instead. Broken links can't be followed.
+func (m Model) operationItems(items []DirectoryItem) []DirectoryItem {
+	operationItems := make([]DirectoryItem, 0, len(items))
+
+	for _, item := range items {
+		switch {
+		case item.IsSymlink && m.followSymlinks && !item.IsBrokenLink:
+			item.Path = item.Target
+			item.Name = filepath.Base(item.Target)
+			item.Target = ""
+			item.IsSymlink = false
+		case item.IsSymlink:
+			item.IsDirectory = false
+		}
+
+		operationItems = append(operationItems, item)
+	}
+
+	return operationItems
+}
+
+// GetOperationItems returns the items an operation on the items provided acts
+// on, which are the targets of symlinks when symlinks are followed.
+func (m Model) GetOperationItems(items []DirectoryItem) []DirectoryItem {
+	return m.operationItems(items)
+}
+
+// ToggleFollowSymlinksCmd switches between operations acting on symlinks
+// themselves and on their targets.
+func (m *Model) ToggleFollowSymlinksCmd() tea.Cmd {
+	m.followSymlinks = !m.followSymlinks
+
+	if m.followSymlinks {
+		return m.NewStatusMessageCmd("Operations follow symlinks to their targets")
+	}
+
+	return m.NewStatusMessageCmd("Operations act on symlinks themselves")
+}
+
+// GetFollowSymlinks returns whether operations act on the targets of symlinks.
+func (m Model) GetFollowSymlinks() bool {
+	return m.followSymlinks
+}
+
+// createLinksCmd links to each of the items provided. When the destination
+// is a directory the links are created within it under the names of the
+// items, otherwise it is the name of the link to the only item.
+func createLinksCmd(
+	items []DirectoryItem,
+	destination string,
+	link func(target, name string) error,
+	j *journal.Journal,
+) tea.Cmd {
+	return func() tea.Msg {
+		info, err := os.Stat(destination)
+		isDirectory := err == nil && info.IsDir()
+
+		if !isDirectory && len(items) > 1 {
+			return errorMsg(fmt.Sprintf("%s is not a directory", destination))
+		}
+
+		var actions []journal.Action
+		defer func() { j.Record("link", actions...) }()
+
+		for _, item := range items {
+			name := destination
+
+			if isDirectory {
+				name = filepath.Join(destination, item.Name)
+			}
+
+			if err := link(item.Path, name); err != nil {
+				return errorMsg(err.Error())
+			}
+
+			actions = append(actions, journal.Created(name))
+		}
+
+		return nil
+	}
+}
+
+// linkDestination returns the destination of new links, which is relative to
+// the current directory unless it is absolute.
+func (m Model) linkDestination(destination string) string {
+	if filepath.IsAbs(destination) {
+		return destination
+	}
+
+	return filepath.Join(m.CurrentDirectory, destination)
+}
+
+// CreateSymlinksCmd creates symlinks to the selected or marked items at the
+// destination provided.
+func (m *Model) CreateSymlinksCmd(destination string) tea.Cmd {
+	m.State = IdleState
+	items := m.operationItems(m.GetSelectedItems())
+	m.ClearMarks()
+
+	return tea.Sequence(
+		createLinksCmd(items, m.linkDestination(destination), filesystem.CreateSymlink, m.journal),
+		m.GetDirectoryListingCmd(m.CurrentDirectory),
+	)
+}
+
+// CreateHardlinksCmd creates hard links to the selected or marked items at
+// the destination provided.
+func (m *Model) CreateHardlinksCmd(destination string) tea.Cmd {
+	m.State = IdleState
+	items := m.operationItems(m.GetSelectedItems())
+	m.ClearMarks()
+
+	return tea.Sequence(
+		createLinksCmd(items, m.linkDestination(destination), filesystem.CreateHardlink, m.journal),
+		m.GetDirectoryListingCmd(m.CurrentDirectory),
+	)
+}
+
+// RenameSelectedItemCmd renames the selected item within its directory, or
+// the target of a symlink when symlinks are followed.
+func (m *Model) RenameSelectedItemCmd(name string, resolve ConflictResolver) tea.Cmd {
+	item := m.operationItems([]DirectoryItem{m.GetSelectedItem()})[0]
+
+	return m.RenameDirectoryItemCmd(item.Path, filepath.Join(filepath.Dir(item.Path), name), resolve)
+}
+
+// UnzipSelectedItemsCmd unzips the selected or marked items, resolving
+// conflicts with files already extracted using resolve.
+func (m *Model) UnzipSelectedItemsCmd(resolve ConflictResolver) tea.Cmd {
+	items := m.GetSelectedItems()
+	m.ClearMarks()
+
+	return tea.Sequence(
+		unzipDirectoryItemsCmd(items, resolve, m.journal),
+		m.GetDirectoryListingCmd(m.CurrentDirectory),
+	)
+}
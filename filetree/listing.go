@@ -0,0 +1,397 @@
+package filetree
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/mistakenelf/fm/filesystem"
+)
+
+// Listings are streamed in batches so that huge directories show up right
+// away, starting with a small batch to fill the screen quickly.
+const (
+	firstBatchSize = 512
+	batchSize      = 4096
+)
+
+// directoryBatch is a batch of items read from a directory.
+type directoryBatch struct {
+	files []DirectoryItem
+	err   error
+}
+
+type directoryBatchMsg struct {
+	id               int
+	workingDirectory string
+	batch            directoryBatch
+	batches          <-chan directoryBatch
+	done             bool
+}
+
+type metadataMsg struct {
+	id    int
+	files map[string]DirectoryItem
+}
+
+// resolveDirectory returns the absolute path of a directory. An empty path is
+// returned when the name provided is not a directory.
+func resolveDirectory(directoryName string) (string, error) {
+	var err error
+
+	if directoryName == filesystem.HomeDirectory {
+		directoryName, err = filesystem.GetHomeDirectory()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	directoryPath, err := filepath.Abs(directoryName)
+	if err != nil {
+		return "", err
+	}
+
+	directoryInfo, err := os.Stat(directoryPath)
+	if err != nil {
+		return "", err
+	}
+
+	if !directoryInfo.IsDir() {
+		return "", nil
+	}
+
+	return directoryPath, nil
+}
+
+// ignoreMatcher returns a matcher for ignore files when ignored items are
+// hidden, nil otherwise.
+func (m Model) ignoreMatcher() *filesystem.IgnoreMatcher {
+	if !m.hideIgnored {
+		return nil
+	}
+
+	return filesystem.NewIgnoreMatcher()
+}
+
+// includeEntry reports whether an entry of a directory is shown with the
+// current hidden file, hide pattern, ignore file and listing type settings.
+func (m Model) includeEntry(ignore *filesystem.IgnoreMatcher, directoryPath string, entry fs.DirEntry) bool {
+	switch {
+	case !m.showHidden && strings.HasPrefix(entry.Name(), "."):
+		return false
+	case m.isHiddenByPattern(entry.Name()):
+		return false
+	case ignore != nil && ignore.Match(filepath.Join(directoryPath, entry.Name()), entry.IsDir()):
+		return false
+	case m.showDirectoriesOnly:
+		return entry.IsDir()
+	case m.showFilesOnly:
+		return !entry.IsDir()
+	default:
+		return true
+	}
+}
+
+// newDirectoryItem creates the item for a directory entry. Only symlinks are
+// resolved here, the rest of the metadata is loaded once the item is shown.
+// Symlinks which can't be resolved are marked as broken.
+func newDirectoryItem(directoryPath string, entry fs.DirEntry) DirectoryItem {
+	path := filepath.Join(directoryPath, entry.Name())
+
+	item := DirectoryItem{
+		Name:        entry.Name(),
+		Path:        path,
+		Extension:   filepath.Ext(entry.Name()),
+		IsDirectory: entry.IsDir(),
+	}
+
+	if entry.Type()&os.ModeSymlink == 0 {
+		return item
+	}
+
+	item.IsSymlink = true
+	item.LinkTarget, _ = os.Readlink(path)
+
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		item.IsBrokenLink = true
+
+		return item
+	}
+
+	targetInfo, err := os.Stat(target)
+	if err != nil {
+		item.IsBrokenLink = true
+
+		return item
+	}
+
+	item.Target = target
+	item.IsDirectory = targetInfo.IsDir()
+
+	return item
+}
+
+// readDirectoryBatches streams the items of a directory in batches until the
+// whole directory is read or ctx is cancelled.
+func (m Model) readDirectoryBatches(ctx context.Context, directoryPath string, batches chan<- directoryBatch) {
+	defer close(batches)
+
+	send := func(batch directoryBatch) bool {
+		select {
+		case batches <- batch:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	directory, err := os.Open(filepath.Clean(directoryPath))
+	if err != nil {
+		send(directoryBatch{err: err})
+
+		return
+	}
+
+	defer func() {
+		_ = directory.Close()
+	}()
+
+	size := firstBatchSize
+	ignore := m.ignoreMatcher()
+
+	for {
+		entries, readErr := directory.ReadDir(size)
+		files := make([]DirectoryItem, 0, len(entries))
+
+		for _, entry := range entries {
+			if !m.includeEntry(ignore, directoryPath, entry) {
+				continue
+			}
+
+			files = append(files, newDirectoryItem(directoryPath, entry))
+		}
+
+		switch {
+		case errors.Is(readErr, io.EOF):
+			if len(files) > 0 {
+				send(directoryBatch{files: files})
+			}
+
+			return
+		case readErr != nil:
+			send(directoryBatch{err: readErr})
+
+			return
+		}
+
+		if !send(directoryBatch{files: files}) {
+			return
+		}
+
+		size = batchSize
+	}
+}
+
+// waitForBatchCmd waits for the next batch of a directory listing.
+func waitForBatchCmd(id int, workingDirectory string, batches <-chan directoryBatch) tea.Cmd {
+	return func() tea.Msg {
+		batch, ok := <-batches
+
+		return directoryBatchMsg{
+			id:               id,
+			workingDirectory: workingDirectory,
+			batch:            batch,
+			batches:          batches,
+			done:             !ok,
+		}
+	}
+}
+
+// handleDirectoryBatch adds a batch of items to the listing being loaded.
+func (m *Model) handleDirectoryBatch(msg directoryBatchMsg) tea.Cmd {
+	if msg.batches != m.listingBatches {
+		return nil
+	}
+
+	if msg.done || msg.batch.err != nil {
+		m.stopListing()
+		m.pruneMarks()
+
+		if msg.batch.err != nil {
+			return func() tea.Msg {
+				return errorMsg(msg.batch.err.Error())
+			}
+		}
+
+		return m.calculateSizesCmd()
+	}
+
+	// Stay at the top while the listing loads unless the cursor was moved,
+	// rather than following the first item as others are sorted before it.
+	atTop := m.Cursor == 0 && m.min == 0
+
+	m.annotateGitStatus(msg.batch.files)
+	m.sortFiles(msg.batch.files)
+	m.unfilteredFiles = m.mergeSortedFiles(m.unfilteredFiles, msg.batch.files)
+	_ = m.applyFilter()
+
+	if atTop {
+		m.moveCursor(0, 0)
+	}
+
+	m.restorePendingSelection()
+
+	return tea.Batch(
+		waitForBatchCmd(m.id, msg.workingDirectory, msg.batches),
+		m.loadSortMetadataCmd(msg.batch.files),
+	)
+}
+
+// stopListing cancels the directory listing being streamed, if any.
+func (m *Model) stopListing() {
+	if m.cancelListing != nil {
+		m.cancelListing()
+	}
+
+	m.cancelListing = nil
+	m.listingBatches = nil
+	m.pendingSelection = viewPosition{}
+}
+
+// restorePendingSelection selects the item which was to be selected once it
+// has been listed.
+func (m *Model) restorePendingSelection() {
+	if m.pendingSelection.selected != "" && m.restoreViewPosition(m.pendingSelection) {
+		m.pendingSelection = viewPosition{}
+	}
+}
+
+// IsLoading reports whether the directory listing is still being read.
+func (m Model) IsLoading() bool {
+	return m.listingBatches != nil
+}
+
+// withMetadata returns the item with its file info, permissions and size.
+func withMetadata(item DirectoryItem) DirectoryItem {
+	item.metadataLoaded = true
+
+	fileInfo, err := os.Lstat(item.Path)
+	if err != nil {
+		return item
+	}
+
+	item.FileInfo = fileInfo
+	item.Details = fileInfo.Mode().String()
+	item.FileSize = filesystem.ConvertBytesToSizeString(fileInfo.Size())
+
+	return item
+}
+
+// sortNeedsMetadata reports whether the sort order depends on file info, in
+// which case metadata is loaded for every item rather than just the visible ones.
+func (m Model) sortNeedsMetadata() bool {
+	return m.sortOrder == SizeSortOrder || m.sortOrder == ModTimeSortOrder || m.sortOrder == TypeSortOrder
+}
+
+// loadMetadataCmd loads the metadata of the items provided in the background.
+func (m *Model) loadMetadataCmd(items []DirectoryItem) tea.Cmd {
+	var pending []DirectoryItem
+
+	for _, item := range items {
+		if item.metadataLoaded || item.Path == "" {
+			continue
+		}
+
+		if _, ok := m.metadataPending[item.Path]; ok {
+			continue
+		}
+
+		m.metadataPending[item.Path] = struct{}{}
+		pending = append(pending, item)
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	id := m.id
+
+	return func() tea.Msg {
+		files := make(map[string]DirectoryItem, len(pending))
+
+		for _, item := range pending {
+			files[item.Path] = withMetadata(item)
+		}
+
+		return metadataMsg{id: id, files: files}
+	}
+}
+
+// loadVisibleMetadataCmd loads the metadata of the rows in view.
+func (m *Model) loadVisibleMetadataCmd() tea.Cmd {
+	start := max(m.min, 0)
+	end := min(m.max+1, len(m.files))
+
+	if start >= end {
+		return nil
+	}
+
+	return m.loadMetadataCmd(m.files[start:end])
+}
+
+// loadSortMetadataCmd loads the metadata of the items provided when it is
+// needed for sorting. It is requested once as items are listed or the sort
+// order changes rather than for every message.
+func (m *Model) loadSortMetadataCmd(files []DirectoryItem) tea.Cmd {
+	if !m.sortNeedsMetadata() {
+		return nil
+	}
+
+	return m.loadMetadataCmd(files)
+}
+
+// fillMetadata copies loaded metadata onto the matching items.
+func fillMetadata(files []DirectoryItem, loaded map[string]DirectoryItem) {
+	for i := range files {
+		item, ok := loaded[files[i].Path]
+		if !ok {
+			continue
+		}
+
+		files[i].FileInfo = item.FileInfo
+		files[i].Details = item.Details
+		files[i].FileSize = item.FileSize
+		files[i].metadataLoaded = true
+	}
+}
+
+// handleMetadata stores loaded metadata on the items of the listing.
+func (m *Model) handleMetadata(msg metadataMsg) {
+	for path, item := range msg.files {
+		delete(m.metadataPending, path)
+
+		// Keep the calculated size of directories rather than their own size.
+		if size, ok := m.directorySizes[item.Path]; ok && item.IsDirectory {
+			item.FileSize = size
+			msg.files[path] = item
+		}
+	}
+
+	fillMetadata(m.unfilteredFiles, msg.files)
+	fillMetadata(m.files, msg.files)
+
+	for _, children := range m.children {
+		fillMetadata(children, msg.files)
+	}
+
+	if m.sortNeedsMetadata() {
+		m.resort()
+	}
+}
@@ -1,7 +1,14 @@
 package filetree
 
 import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+
+	"github.com/mistakenelf/fm/internal/views"
 )
 
 // SetDisabled sets if the bubble is currently active.
@@ -18,22 +25,394 @@ func (m Model) GetSelectedItem() DirectoryItem {
 	return DirectoryItem{}
 }
 
+// GetMarkedItems returns all marked files/dirs in listing order, including
+// those currently hidden by the filter.
+func (m Model) GetMarkedItems() []DirectoryItem {
+	var items []DirectoryItem
+
+	for _, file := range m.allFiles() {
+		if m.IsMarked(file) {
+			items = append(items, file)
+		}
+	}
+
+	return items
+}
+
+// GetSelectedItems returns the marked files/dirs, falling back to the
+// currently selected item when nothing is marked.
+func (m Model) GetSelectedItems() []DirectoryItem {
+	if len(m.marked) > 0 {
+		return m.GetMarkedItems()
+	}
+
+	if len(m.files) > 0 {
+		return []DirectoryItem{m.files[m.Cursor]}
+	}
+
+	return nil
+}
+
+// GetTotalMarked returns the number of marked items.
+func (m Model) GetTotalMarked() int {
+	return len(m.marked)
+}
+
+// IsMarked returns whether the given item is marked.
+func (m Model) IsMarked(item DirectoryItem) bool {
+	_, ok := m.marked[item.Path]
+
+	return ok
+}
+
+// ToggleMark toggles the mark on the currently selected item.
+func (m *Model) ToggleMark() {
+	if len(m.files) == 0 {
+		return
+	}
+
+	path := m.files[m.Cursor].Path
+
+	if _, ok := m.marked[path]; ok {
+		delete(m.marked, path)
+	} else {
+		m.marked[path] = struct{}{}
+	}
+}
+
+// MarkAll marks every item in the current listing.
+func (m *Model) MarkAll() {
+	for _, file := range m.files {
+		m.marked[file.Path] = struct{}{}
+	}
+}
+
+// InvertMarks marks every unmarked item and unmarks every marked one.
+func (m *Model) InvertMarks() {
+	for _, file := range m.files {
+		if _, ok := m.marked[file.Path]; ok {
+			delete(m.marked, file.Path)
+		} else {
+			m.marked[file.Path] = struct{}{}
+		}
+	}
+}
+
+// ClearMarks unmarks all items.
+func (m *Model) ClearMarks() {
+	m.marked = make(map[string]struct{})
+}
+
+// pruneMarks drops marks for items no longer in the listing.
+func (m *Model) pruneMarks() {
+	files := m.allFiles()
+	listed := make(map[string]struct{}, len(files))
+
+	for _, file := range files {
+		listed[file.Path] = struct{}{}
+	}
+
+	for path := range m.marked {
+		if _, ok := listed[path]; !ok {
+			delete(m.marked, path)
+		}
+	}
+}
+
+// MarkByGlob marks every item whose name matches the glob pattern and
+// returns the number of newly marked items.
+func (m *Model) MarkByGlob(pattern string) (int, error) {
+	count := 0
+
+	for _, file := range m.files {
+		matched, err := filepath.Match(pattern, file.Name)
+		if err != nil {
+			return count, err
+		}
+
+		if _, ok := m.marked[file.Path]; matched && !ok {
+			m.marked[file.Path] = struct{}{}
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// scrollToCursor adjusts the visible window so the cursor is shown.
+func (m *Model) scrollToCursor() {
+	if m.Cursor < m.min {
+		m.min = m.Cursor
+		m.max = m.min + m.height - 1
+	}
+
+	if m.Cursor > m.max {
+		m.max = m.Cursor
+		m.min = m.max - m.height + 1
+	}
+}
+
+// getViewPosition returns the selected item and scroll offset, or those
+// still waiting for their item to be listed.
+func (m Model) getViewPosition() viewPosition {
+	if m.pendingSelection.selected != "" {
+		return m.pendingSelection
+	}
+
+	return viewPosition{selected: m.GetSelectedItem().Path, min: m.min}
+}
+
+// restoreViewPosition moves the cursor onto the item selected in the position
+// provided, scrolled to its offset when that keeps it in view. It reports
+// whether the item is listed, leaving the cursor alone when it isn't.
+func (m *Model) restoreViewPosition(position viewPosition) bool {
+	for i, file := range m.files {
+		if file.Path == position.selected {
+			m.moveCursor(i, position.min)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// moveCursor moves the cursor and scroll offset, keeping both within the
+// bounds of the current listing.
+func (m *Model) moveCursor(cursor, offset int) {
+	m.Cursor = max(min(cursor, len(m.files)-1), 0)
+	m.min = max(min(offset, len(m.files)-m.height), 0)
+	m.max = m.min + m.height - 1
+	m.scrollToCursor()
+}
+
+// getFilterMatcher returns a function reporting whether a name matches the
+// current filter, or nil when there is no filter.
+func (m Model) getFilterMatcher() (func(name string) bool, error) {
+	if m.filter == "" {
+		return nil, nil
+	}
+
+	switch m.filterMode {
+	case GlobFilterMode:
+		if _, err := filepath.Match(m.filter, ""); err != nil {
+			return nil, err
+		}
+
+		return func(name string) bool {
+			matched, _ := filepath.Match(m.filter, name)
+
+			return matched
+		}, nil
+	case RegexFilterMode:
+		re, err := regexp.Compile(m.filter)
+		if err != nil {
+			return nil, err
+		}
+
+		return re.MatchString, nil
+	default:
+		// Use smart case, only matching case sensitively when the
+		// filter contains an upper case character.
+		if strings.ToLower(m.filter) == m.filter {
+			return func(name string) bool {
+				return strings.Contains(strings.ToLower(name), m.filter)
+			}, nil
+		}
+
+		return func(name string) bool {
+			return strings.Contains(name, m.filter)
+		}, nil
+	}
+}
+
+// visibleFiles returns the items to display, narrowed down by the filter and
+// with the contents of expanded directories nested in the tree view.
+func (m Model) visibleFiles() ([]DirectoryItem, error) {
+	match, err := m.getFilterMatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	if m.treeView {
+		return m.flattenTree(m.unfilteredFiles, match, 0, "", nil), nil
+	}
+
+	if match == nil {
+		return m.unfilteredFiles, nil
+	}
+
+	filtered := make([]DirectoryItem, 0, len(m.unfilteredFiles))
+
+	for _, file := range m.unfilteredFiles {
+		if match(file.Name) {
+			filtered = append(filtered, file)
+		}
+	}
+
+	return filtered, nil
+}
+
+// allFiles returns every loaded item regardless of the filter, including the
+// contents of expanded directories in the tree view.
+func (m Model) allFiles() []DirectoryItem {
+	if m.treeView {
+		return m.flattenTree(m.unfilteredFiles, nil, 0, "", nil)
+	}
+
+	return m.unfilteredFiles
+}
+
+// applyFilter narrows the listing down to the items matching the filter,
+// keeping the cursor on the same item when it is still visible and otherwise
+// keeping the scroll position.
+func (m *Model) applyFilter() error {
+	files, err := m.visibleFiles()
+	if err != nil {
+		return err
+	}
+
+	position := viewPosition{selected: m.GetSelectedItem().Path, min: m.min}
+	cursor := m.Cursor
+
+	m.files = files
+
+	if !m.restoreViewPosition(position) {
+		m.moveCursor(cursor, position.min)
+	}
+
+	return nil
+}
+
+// SetFilter sets the filter used to narrow down the listing. An invalid glob
+// or regex leaves the current listing untouched. The filter is remembered for
+// the current directory and saved once it is left.
+func (m *Model) SetFilter(filter string) error {
+	m.filter = filter
+	m.rememberView()
+
+	return m.applyFilter()
+}
+
+// ClearFilter removes the filter and shows all items again.
+func (m *Model) ClearFilter() {
+	if m.filter == "" {
+		return
+	}
+
+	_ = m.SetFilter("")
+}
+
+// GetFilter returns the current filter.
+func (m Model) GetFilter() string {
+	return m.filter
+}
+
+// GetFilterMode returns how the filter is matched against item names.
+func (m Model) GetFilterMode() FilterMode {
+	return m.filterMode
+}
+
+// CycleFilterMode switches between substring, glob and regex matching.
+func (m *Model) CycleFilterMode() error {
+	m.filterMode = (m.filterMode + 1) % (RegexFilterMode + 1)
+	m.rememberView()
+
+	return m.applyFilter()
+}
+
+// resort reorders the listing after the sort settings change.
+func (m *Model) resort() {
+	m.sortFiles(m.unfilteredFiles)
+
+	for _, children := range m.children {
+		m.sortFiles(children)
+	}
+
+	_ = m.applyFilter()
+}
+
+// CycleSortOrder switches to the next sort order, returning the command
+// saving it.
+func (m *Model) CycleSortOrder() tea.Cmd {
+	sortOrder := (m.sortOrder + 1) % (TypeSortOrder + 1)
+	cmd := m.changeViewCmd(func(settings *views.Settings) { settings.SortOrder = int(sortOrder) })
+	m.resort()
+
+	return tea.Batch(cmd, m.loadSortMetadataCmd(m.allFiles()))
+}
+
+// ToggleSortReversed reverses the sort order, returning the command saving
+// it.
+func (m *Model) ToggleSortReversed() tea.Cmd {
+	reversed := !m.sortReversed
+	cmd := m.changeViewCmd(func(settings *views.Settings) { settings.SortReversed = reversed })
+	m.resort()
+
+	return cmd
+}
+
+// ToggleDirectoriesFirst toggles listing directories before files, returning
+// the command saving it.
+func (m *Model) ToggleDirectoriesFirst() tea.Cmd {
+	directoriesFirst := !m.directoriesFirst
+	cmd := m.changeViewCmd(func(settings *views.Settings) { settings.DirectoriesFirst = directoriesFirst })
+	m.resort()
+
+	return cmd
+}
+
+// GetSortDescription returns a short description of the sort settings.
+func (m Model) GetSortDescription() string {
+	description := m.sortOrder.String()
+
+	if m.sortReversed {
+		description += " (rev)"
+	}
+
+	if m.directoriesFirst {
+		description += ", dirs first"
+	}
+
+	return description
+}
+
 // GetTotalItems returns total number of tree items.
 func (m Model) GetTotalItems() int {
 	return len(m.files)
 }
 
+// SetHidePatterns sets the glob patterns of names which are always hidden.
+func (m *Model) SetHidePatterns(patterns []string) {
+	m.hidePatterns = patterns
+}
+
+// SetViewStore sets where the view settings of each directory are remembered.
+// Directories which have none are listed with the current settings, which
+// follow the changes made during the session.
+func (m *Model) SetViewStore(store *views.Store) {
+	m.views = store
+	m.sessionView = m.viewSettings()
+}
+
+// GetHideIgnored returns whether items matched by ignore files are hidden.
+func (m Model) GetHideIgnored() bool {
+	return m.hideIgnored
+}
+
 // SetSize Sets the size of the filetree.
 func (m *Model) SetSize(width, height int) {
 	m.height = height
 	m.width = width
-	m.max = m.height - 1
+	m.max = m.min + m.height - 1
+	m.scrollToCursor()
 }
 
 // SetTheme sets the theme of the tree.
 func (m *Model) SetTheme(selectedItemColor, unselectedItemColor lipgloss.AdaptiveColor) {
 	m.selectedItemColor = selectedItemColor
 	m.unselectedItemColor = unselectedItemColor
+	m.styles = newRowStyles(selectedItemColor, unselectedItemColor, m.inactiveItemColor)
 }
 
 // SetSelectionPath sets the selection path to be written.
@@ -45,3 +424,20 @@ func (m *Model) SetSelectionPath(path string) {
 func (m *Model) SetShowIcons(show bool) {
 	m.showIcons = show
 }
+
+// SetCalculateSizes sets whether the sizes of directories are calculated in
+// the background.
+func (m *Model) SetCalculateSizes(calculate bool) {
+	m.calculateSizes = calculate
+}
+
+// SetShowGitStatus sets whether the git status of items is shown when the
+// current directory is within a git work tree.
+func (m *Model) SetShowGitStatus(show bool) {
+	m.showGitStatus = show
+}
+
+// SetShowDetails sets whether the detailed columns view is shown.
+func (m *Model) SetShowDetails(show bool) {
+	m.showDetails = show
+}
@@ -1,12 +1,18 @@
 package filetree
 
 import (
+	"context"
 	"os"
+	"sync/atomic"
 	"time"
 
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/lipgloss"
 
 	"github.com/mistakenelf/fm/filesystem"
+	"github.com/mistakenelf/fm/internal/gitrepo"
+	"github.com/mistakenelf/fm/internal/journal"
+	"github.com/mistakenelf/fm/internal/views"
 	"github.com/mistakenelf/fm/keys"
 )
 
@@ -18,20 +24,118 @@ const (
 	CreateDirectoryState
 	MoveState
 	RenameState
+	MarkByGlobState
+	FilterState
+	AddBookmarkState
+	JumpState
+	CreateSymlinkState
+	CreateHardlinkState
 )
 
+// FilterMode determines how the filter is matched against item names.
+type FilterMode int
+
+const (
+	SubstringFilterMode FilterMode = iota
+	GlobFilterMode
+	RegexFilterMode
+)
+
+// String returns the name of the filter mode.
+func (f FilterMode) String() string {
+	switch f {
+	case GlobFilterMode:
+		return "glob"
+	case RegexFilterMode:
+		return "regex"
+	default:
+		return "substring"
+	}
+}
+
+// SortOrder determines how directory items are ordered.
+type SortOrder int
+
+const (
+	NameSortOrder SortOrder = iota
+	SizeSortOrder
+	ModTimeSortOrder
+	ExtensionSortOrder
+	TypeSortOrder
+)
+
+// String returns the name of the sort order.
+func (s SortOrder) String() string {
+	switch s {
+	case SizeSortOrder:
+		return "size"
+	case ModTimeSortOrder:
+		return "modified"
+	case ExtensionSortOrder:
+		return "extension"
+	case TypeSortOrder:
+		return "type"
+	default:
+		return "name"
+	}
+}
+
+// DirectoryItem is an item of a listing. For symlinks Path is the link
+// itself, Target is where it resolves to and IsDirectory describes the target.
 type DirectoryItem struct {
-	Name        string
-	Details     string
-	Path        string
-	Extension   string
-	FileSize    string
-	IsDirectory bool
-	FileInfo    os.FileInfo
+	Name         string
+	Details      string
+	Path         string
+	Target       string
+	Extension    string
+	FileSize     string
+	LinkTarget   string
+	IsDirectory  bool
+	IsSymlink    bool
+	IsBrokenLink bool
+	FileInfo     os.FileInfo
+	GitStatus    gitrepo.FileStatus
+	Depth        int
+	guide        string
+
+	metadataLoaded bool
+}
+
+// ResolvedPath returns the path the item resolves to, which is the target of
+// a symlink and the path of anything else.
+func (d DirectoryItem) ResolvedPath() string {
+	if d.Target != "" {
+		return d.Target
+	}
+
+	return d.Path
+}
+
+// viewPosition is the selected item and scroll offset within a directory.
+// The item is kept by path since it may move as more of the listing loads.
+type viewPosition struct {
+	selected string
+	min      int
+}
+
+// lastID is used to give each filetree a unique id so that messages are only
+// handled by the filetree which requested them.
+var lastID int64
+
+func nextID() int {
+	return int(atomic.AddInt64(&lastID, 1))
 }
 
 type Model struct {
+	id                    int
 	files                 []DirectoryItem
+	unfilteredFiles       []DirectoryItem
+	filter                string
+	filterMode            FilterMode
+	sortOrder             SortOrder
+	sortReversed          bool
+	directoriesFirst      bool
+	marked                map[string]struct{}
 	Cursor                int
 	min                   int
 	max                   int
@@ -39,9 +143,41 @@ type Model struct {
 	width                 int
 	Disabled              bool
 	showHidden            bool
+	hideIgnored           bool
+	hidePatterns          []string
+	followSymlinks        bool
+	views                 *views.Store
+	journal               *journal.Journal
+	sessionView           views.Settings
+	unsavedView           bool
 	showDirectoriesOnly   bool
 	showFilesOnly         bool
 	showIcons             bool
+	showDetails           bool
+	calculateSizes        bool
+	showGitStatus         bool
+	gitStatus             *gitrepo.Status
+	gitStatusLoading      bool
+	gitStatusStale        bool
+	treeView              bool
+	expanded              map[string]struct{}
+	children              map[string][]DirectoryItem
+	expandDepth           int
+	count                 int
+	history               []historyEntry
+	historyIndex          int
+	positions             map[string]viewPosition
+	watcher               *directoryWatcher
+	cancelListing         context.CancelFunc
+	listingBatches        <-chan directoryBatch
+	pendingSelection      viewPosition
+	metadataPending       map[string]struct{}
+	sizesPending          map[string]struct{}
+	directorySizes        map[string]string
+	cancelSizes           context.CancelFunc
+	sizeResults           <-chan directorySize
+	spinner               spinner.Model
+	styles                rowStyles
 	keyMap                keys.KeyMap
 	startDir              string
 	StatusMessage         string
@@ -63,8 +199,21 @@ func New(startDir string) Model {
 		startingDirectory = startDir
 	}
 
+	selectedItemColor := lipgloss.AdaptiveColor{Light: "212", Dark: "212"}
+	unselectedItemColor := lipgloss.AdaptiveColor{Light: "ffffff", Dark: "#000000"}
+	inactiveItemColor := lipgloss.AdaptiveColor{Light: "243", Dark: "243"}
+
 	return Model{
+		id:                    nextID(),
 		Cursor:                0,
+		marked:                make(map[string]struct{}),
+		expanded:              make(map[string]struct{}),
+		children:              make(map[string][]DirectoryItem),
+		positions:             make(map[string]viewPosition),
+		metadataPending:       make(map[string]struct{}),
+		sizesPending:          make(map[string]struct{}),
+		directorySizes:        make(map[string]string),
+		spinner:               spinner.New(spinner.WithSpinner(spinner.MiniDot)),
 		Disabled:              false,
 		keyMap:                keys.DefaultKeyMap(),
 		min:                   0,
@@ -75,9 +224,12 @@ func New(startDir string) Model {
 		StatusMessageLifetime: time.Second,
 		showFilesOnly:         false,
 		showDirectoriesOnly:   false,
-		selectedItemColor:     lipgloss.AdaptiveColor{Light: "212", Dark: "212"},
-		unselectedItemColor:   lipgloss.AdaptiveColor{Light: "ffffff", Dark: "#000000"},
-		inactiveItemColor:     lipgloss.AdaptiveColor{Light: "243", Dark: "243"},
+		selectedItemColor:     selectedItemColor,
+		unselectedItemColor:   unselectedItemColor,
+		inactiveItemColor:     inactiveItemColor,
+		styles:                newRowStyles(selectedItemColor, unselectedItemColor, inactiveItemColor),
 		showIcons:             true,
+		calculateSizes:        true,
+		showGitStatus:         true,
 	}
 }
@@ -0,0 +1,119 @@
+package filetree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/mistakenelf/fm/filesystem"
+	"github.com/mistakenelf/fm/internal/journal"
+)
+
+type pasteMsg struct {
+	id    int
+	count int
+	cut   bool
+}
+
+// PasteDestinations returns where each of the items at the paths provided is
+// pasted in the current directory. A copy pasted into the directory of its
+// original gets a new name, while a cut item pasted there stays put and has no
+// destination. Items can't be pasted into themselves or onto each other.
+func (m Model) PasteDestinations(paths []string, cut bool) ([]string, error) {
+	directory := m.CurrentDirectory
+	destinations := make([]string, 0, len(paths))
+	sources := make(map[string]string, len(paths))
+
+	for _, path := range paths {
+		if strings.HasPrefix(directory+string(os.PathSeparator), path+string(os.PathSeparator)) {
+			return nil, fmt.Errorf("can't paste %s into itself", path)
+		}
+
+		destination := filepath.Join(directory, filepath.Base(path))
+
+		if destination == path {
+			if cut {
+				destinations = append(destinations, "")
+
+				continue
+			}
+
+			destination = filesystem.CopyPath(path)
+		}
+
+		if source, ok := sources[destination]; ok {
+			return nil, fmt.Errorf("can't paste both %s and %s as %s", source, path, destination)
+		}
+
+		sources[destination] = path
+		destinations = append(destinations, destination)
+	}
+
+	return destinations, nil
+}
+
+// PasteCmd copies the items at the paths provided into the current directory,
+// or moves them there when they were cut, as laid out by PasteDestinations.
+// Conflicts with items of the current directory are resolved using resolve.
+func (m Model) PasteCmd(paths []string, cut bool, resolve ConflictResolver) tea.Cmd {
+	j := m.journal
+
+	destinations, err := m.PasteDestinations(paths, cut)
+	if err != nil {
+		return func() tea.Msg {
+			return errorMsg(err.Error())
+		}
+	}
+
+	return func() tea.Msg {
+		var actions []journal.Action
+
+		name := "paste"
+		if cut {
+			name = "move"
+		}
+
+		defer func() { j.Record(name, actions...) }()
+
+		handler := conflictHandler(resolve, &actions)
+		count := 0
+
+		for i, path := range paths {
+			destination := destinations[i]
+			if destination == "" {
+				continue
+			}
+
+			if cut {
+				output, err := filesystem.MoveDirectoryItem(path, destination, handler)
+				if err != nil {
+					return errorMsg(err.Error())
+				}
+
+				if output == "" {
+					continue
+				}
+
+				actions = append(actions, journal.Moved(path, output))
+			} else {
+				output, err := filesystem.CopyDirectoryItem(path, destination, handler)
+				if err != nil {
+					return errorMsg(err.Error())
+				}
+
+				if output == "" {
+					continue
+				}
+
+				actions = append(actions, journal.Created(output))
+			}
+
+			count++
+		}
+
+		return pasteMsg{id: m.id, count: count, cut: cut}
+	}
+}
@@ -0,0 +1,244 @@
+package filetree
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/mistakenelf/fm/filesystem"
+)
+
+// sizeWorkers is how many directory sizes are calculated at once.
+const sizeWorkers = 4
+
+// cachedSize is the calculated size of a directory as of its mtime.
+type cachedSize struct {
+	modTime time.Time
+	size    int64
+}
+
+// sizeCache holds the directory sizes calculated by every filetree, keyed by
+// path, so that revisiting a directory doesn't walk it again.
+var sizeCache = struct {
+	sync.Mutex
+	sizes map[string]cachedSize
+}{sizes: make(map[string]cachedSize)}
+
+// directorySize is the result of calculating the size of a directory.
+type directorySize struct {
+	path string
+	size string
+}
+
+type directorySizeMsg struct {
+	id      int
+	result  directorySize
+	results <-chan directorySize
+	done    bool
+}
+
+// calculateDirectorySize returns the recursive size of a directory, using the
+// cached size when the directory hasn't been modified since. Symlinks are
+// resolved so that the size of their target is calculated.
+func calculateDirectorySize(ctx context.Context, path string) (int64, error) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	sizeCache.Lock()
+	cached, ok := sizeCache.sizes[path]
+	sizeCache.Unlock()
+
+	if ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.size, nil
+	}
+
+	size, err := filesystem.GetDirectoryItemSizeContext(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+
+	sizeCache.Lock()
+	sizeCache.sizes[path] = cachedSize{modTime: info.ModTime(), size: size}
+	sizeCache.Unlock()
+
+	return size, nil
+}
+
+// calculateDirectorySizes calculates the size of each directory on a pool of
+// workers, sending the results until they are all done or ctx is cancelled.
+func calculateDirectorySizes(ctx context.Context, paths []string, results chan<- directorySize) {
+	var wg sync.WaitGroup
+
+	jobs := make(chan string)
+
+	for i := 0; i < min(sizeWorkers, len(paths)); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for path := range jobs {
+				size, err := calculateDirectorySize(ctx, path)
+				if ctx.Err() != nil {
+					return
+				}
+
+				result := directorySize{path: path, size: "?"}
+				if err == nil {
+					result.size = filesystem.ConvertBytesToSizeString(size)
+				}
+
+				select {
+				case results <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	for _, path := range paths {
+		select {
+		case jobs <- path:
+		case <-ctx.Done():
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+	close(results)
+}
+
+// waitForSizeCmd waits for the next directory size to be calculated.
+func waitForSizeCmd(id int, results <-chan directorySize) tea.Cmd {
+	return func() tea.Msg {
+		result, ok := <-results
+
+		return directorySizeMsg{
+			id:      id,
+			result:  result,
+			results: results,
+			done:    !ok,
+		}
+	}
+}
+
+// calculateSizesCmd starts calculating the sizes of the directories in the
+// listing in the background, cancelling any calculation still running.
+func (m *Model) calculateSizesCmd() tea.Cmd {
+	var paths []string
+
+	for _, file := range m.unfilteredFiles {
+		if file.IsDirectory {
+			paths = append(paths, file.Path)
+		}
+	}
+
+	return m.calculateSizesOfCmd(paths)
+}
+
+// calculateChangedSizesCmd calculates the sizes of the directories provided
+// which changed. Should a calculation still be running, it is started again
+// for the whole listing, where the sizes already calculated are cached.
+func (m *Model) calculateChangedSizesCmd(paths []string) tea.Cmd {
+	if m.sizeResults != nil {
+		return m.calculateSizesCmd()
+	}
+
+	return m.calculateSizesOfCmd(paths)
+}
+
+// calculateSizesOfCmd starts calculating the sizes of the directories
+// provided in the background, cancelling any calculation still running.
+func (m *Model) calculateSizesOfCmd(paths []string) tea.Cmd {
+	m.stopSizes()
+
+	if !m.calculateSizes || len(paths) == 0 {
+		return nil
+	}
+
+	for _, path := range paths {
+		m.sizesPending[path] = struct{}{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan directorySize)
+
+	m.cancelSizes = cancel
+	m.sizeResults = results
+
+	go calculateDirectorySizes(ctx, paths, results)
+
+	return tea.Batch(waitForSizeCmd(m.id, results), m.spinner.Tick)
+}
+
+// stopSizes cancels the directory sizes being calculated, if any.
+func (m *Model) stopSizes() {
+	if m.cancelSizes != nil {
+		m.cancelSizes()
+	}
+
+	m.cancelSizes = nil
+	m.sizeResults = nil
+	m.sizesPending = make(map[string]struct{})
+}
+
+// setDirectorySize shows the size calculated for a directory.
+func setDirectorySize(files []DirectoryItem, result directorySize) {
+	for i := range files {
+		if files[i].IsDirectory && files[i].Path == result.path {
+			files[i].FileSize = result.size
+		}
+	}
+}
+
+// handleDirectorySize stores the size calculated for a directory.
+func (m *Model) handleDirectorySize(msg directorySizeMsg) tea.Cmd {
+	if msg.results != m.sizeResults {
+		return nil
+	}
+
+	if msg.done {
+		m.stopSizes()
+
+		return nil
+	}
+
+	delete(m.sizesPending, msg.result.path)
+	m.directorySizes[msg.result.path] = msg.result.size
+
+	setDirectorySize(m.unfilteredFiles, msg.result)
+	setDirectorySize(m.files, msg.result)
+
+	return waitForSizeCmd(m.id, msg.results)
+}
+
+// isSizePending reports whether the size of an item is still being calculated.
+func (m Model) isSizePending(item DirectoryItem) bool {
+	_, ok := m.sizesPending[item.Path]
+
+	return item.IsDirectory && item.Depth == 0 && ok
+}
+
+// GetSelectedItemSize returns the size of the selected item, or a spinner
+// while the size of a directory is being calculated.
+func (m Model) GetSelectedItemSize() string {
+	item := m.GetSelectedItem()
+
+	if m.isSizePending(item) {
+		return m.spinner.View()
+	}
+
+	return item.FileSize
+}
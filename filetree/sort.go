@@ -0,0 +1,170 @@
+package filetree
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// naturalLess compares two names case insensitively, treating runs of digits
+// as numbers so that "file2" sorts before "file10".
+func naturalLess(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+
+	for a != "" && b != "" {
+		aDigits := leadingDigits(a)
+		bDigits := leadingDigits(b)
+
+		if aDigits != "" && bDigits != "" {
+			aNumber := strings.TrimLeft(aDigits, "0")
+			bNumber := strings.TrimLeft(bDigits, "0")
+
+			if len(aNumber) != len(bNumber) {
+				return len(aNumber) < len(bNumber)
+			}
+
+			if aNumber != bNumber {
+				return aNumber < bNumber
+			}
+
+			if len(aDigits) != len(bDigits) {
+				return len(aDigits) < len(bDigits)
+			}
+
+			a, b = a[len(aDigits):], b[len(bDigits):]
+
+			continue
+		}
+
+		aRune, aSize := utf8.DecodeRuneInString(a)
+		bRune, bSize := utf8.DecodeRuneInString(b)
+
+		if aRune != bRune {
+			return aRune < bRune
+		}
+
+		a, b = a[aSize:], b[bSize:]
+	}
+
+	return len(a) < len(b)
+}
+
+// leadingDigits returns the run of digits at the start of s.
+func leadingDigits(s string) string {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r)
+	})
+
+	if end == -1 {
+		return s
+	}
+
+	return s[:end]
+}
+
+// typeRank orders items by kind: directories, symlinks, regular files and
+// then everything else.
+func typeRank(item DirectoryItem) int {
+	switch {
+	case item.IsDirectory:
+		return 0
+	case item.FileInfo != nil && item.FileInfo.Mode()&os.ModeSymlink != 0:
+		return 1
+	case item.FileInfo != nil && item.FileInfo.Mode().IsRegular():
+		return 2
+	default:
+		return 3
+	}
+}
+
+// compareItems compares two items according to the sort order, returning a
+// negative number when a sorts before b and a positive number when after.
+func compareItems(a, b DirectoryItem, order SortOrder) int {
+	switch order {
+	case SizeSortOrder:
+		if a.FileInfo != nil && b.FileInfo != nil && a.FileInfo.Size() != b.FileInfo.Size() {
+			// Largest first, the same as ls -S.
+			if a.FileInfo.Size() > b.FileInfo.Size() {
+				return -1
+			}
+
+			return 1
+		}
+	case ModTimeSortOrder:
+		if a.FileInfo != nil && b.FileInfo != nil && !a.FileInfo.ModTime().Equal(b.FileInfo.ModTime()) {
+			// Newest first, the same as ls -t.
+			if a.FileInfo.ModTime().After(b.FileInfo.ModTime()) {
+				return -1
+			}
+
+			return 1
+		}
+	case ExtensionSortOrder:
+		aExtension, bExtension := strings.ToLower(a.Extension), strings.ToLower(b.Extension)
+		if aExtension != bExtension {
+			return strings.Compare(aExtension, bExtension)
+		}
+	case TypeSortOrder:
+		if typeRank(a) != typeRank(b) {
+			return typeRank(a) - typeRank(b)
+		}
+	}
+
+	switch {
+	case naturalLess(a.Name, b.Name):
+		return -1
+	case naturalLess(b.Name, a.Name):
+		return 1
+	default:
+		return strings.Compare(a.Name, b.Name)
+	}
+}
+
+// less reports whether a sorts before b using the current sort settings.
+func (m Model) less(a, b DirectoryItem) bool {
+	if m.directoriesFirst && a.IsDirectory != b.IsDirectory {
+		return a.IsDirectory
+	}
+
+	// Items whose metadata is still loading can't be compared by it, so they
+	// go after the loaded ones either way round to keep the order total.
+	if m.sortNeedsMetadata() && (a.FileInfo == nil) != (b.FileInfo == nil) {
+		return a.FileInfo != nil
+	}
+
+	result := compareItems(a, b, m.sortOrder)
+
+	if m.sortReversed {
+		return result > 0
+	}
+
+	return result < 0
+}
+
+// sortFiles orders the items in place using the current sort settings.
+func (m Model) sortFiles(files []DirectoryItem) {
+	sort.SliceStable(files, func(i, j int) bool {
+		return m.less(files[i], files[j])
+	})
+}
+
+// mergeSortedFiles merges two sorted lists of items into a new sorted list.
+func (m Model) mergeSortedFiles(a, b []DirectoryItem) []DirectoryItem {
+	merged := make([]DirectoryItem, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if m.less(b[0], a[0]) {
+			merged = append(merged, b[0])
+			b = b[1:]
+		} else {
+			merged = append(merged, a[0])
+			a = a[1:]
+		}
+	}
+
+	merged = append(merged, a...)
+
+	return append(merged, b...)
+}
@@ -0,0 +1,230 @@
+package filetree
+
+import (
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Guide lines drawn in front of items nested in the tree view.
+const (
+	branchGuide     = "├─ "
+	lastBranchGuide = "└─ "
+	verticalGuide   = "│  "
+	emptyGuide      = "   "
+)
+
+// isExpanded returns whether a directory is expanded in the tree view.
+func (m Model) isExpanded(item DirectoryItem) bool {
+	_, ok := m.expanded[item.Path]
+
+	return item.IsDirectory && ok
+}
+
+// flattenTree returns the items with the loaded contents of expanded
+// directories nested beneath them. Expanded directories are kept even when
+// they don't match the filter so that matching children remain reachable.
+func (m Model) flattenTree(
+	files []DirectoryItem,
+	match func(name string) bool,
+	depth int,
+	guide string,
+	ancestors []string,
+) []DirectoryItem {
+	visible := make([]DirectoryItem, 0, len(files))
+
+	for _, file := range files {
+		if match == nil || match(file.Name) || m.isExpanded(file) {
+			visible = append(visible, file)
+		}
+	}
+
+	flattened := make([]DirectoryItem, 0, len(visible))
+
+	for i, file := range visible {
+		isLast := i == len(visible)-1
+
+		file.Depth = depth
+		file.guide = ""
+
+		if depth > 0 {
+			file.guide = guide + branchGuide
+
+			if isLast {
+				file.guide = guide + lastBranchGuide
+			}
+		}
+
+		flattened = append(flattened, file)
+
+		children, loaded := m.children[file.Path]
+		if !m.isExpanded(file) || !loaded || containsPath(ancestors, file.ResolvedPath()) {
+			continue
+		}
+
+		childGuide := guide
+
+		if depth > 0 {
+			childGuide = guide + verticalGuide
+
+			if isLast {
+				childGuide = guide + emptyGuide
+			}
+		}
+
+		flattened = append(
+			flattened,
+			m.flattenTree(children, match, depth+1, childGuide, append(ancestors, file.ResolvedPath()))...,
+		)
+	}
+
+	return flattened
+}
+
+// containsPath returns whether the path is within the list of paths. It is
+// used to avoid following symlinks back into an ancestor forever.
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expandDirectoryCmd expands a directory in the tree view, loading its contents.
+func (m *Model) expandDirectoryCmd(item DirectoryItem) tea.Cmd {
+	if !item.IsDirectory {
+		return nil
+	}
+
+	m.expanded[item.Path] = struct{}{}
+
+	return m.getChildListingCmd(item.Path)
+}
+
+// ToggleExpandCmd expands or collapses the selected directory in the tree view.
+func (m *Model) ToggleExpandCmd() tea.Cmd {
+	item := m.GetSelectedItem()
+
+	if m.isExpanded(item) {
+		delete(m.expanded, item.Path)
+		_ = m.applyFilter()
+
+		return nil
+	}
+
+	return m.expandDirectoryCmd(item)
+}
+
+// ExpandAllCmd expands every directory in the tree view down to the given depth.
+func (m *Model) ExpandAllCmd(depth int) tea.Cmd {
+	var cmds []tea.Cmd
+
+	m.expandDepth = depth
+
+	for _, file := range m.files {
+		if file.IsDirectory && file.Depth < depth {
+			cmds = append(cmds, m.expandDirectoryCmd(file))
+		}
+	}
+
+	return tea.Batch(cmds...)
+}
+
+// CollapseAll collapses every directory in the tree view at or below the
+// given depth.
+func (m *Model) CollapseAll(depth int) {
+	m.expandDepth = 0
+
+	for _, file := range m.files {
+		if file.Depth >= depth {
+			delete(m.expanded, file.Path)
+		}
+	}
+
+	// Also collapse directories hidden beneath collapsed parents.
+	for path := range m.expanded {
+		relPath, err := filepath.Rel(m.CurrentDirectory, path)
+		if err != nil || strings.HasPrefix(relPath, "..") {
+			continue
+		}
+
+		if strings.Count(relPath, string(filepath.Separator)) >= depth {
+			delete(m.expanded, path)
+		}
+	}
+
+	_ = m.applyFilter()
+}
+
+// ToggleTreeViewCmd switches between the flat listing and the tree view.
+func (m *Model) ToggleTreeViewCmd() tea.Cmd {
+	m.treeView = !m.treeView
+	_ = m.applyFilter()
+
+	if m.treeView {
+		return m.refreshExpandedCmd()
+	}
+
+	return nil
+}
+
+// refreshExpandedCmd reloads the contents of all visible expanded directories
+// so the tree view picks up changes on disk.
+func (m Model) refreshExpandedCmd() tea.Cmd {
+	var cmds []tea.Cmd
+
+	if !m.treeView {
+		return nil
+	}
+
+	for _, file := range m.files {
+		if m.isExpanded(file) {
+			cmds = append(cmds, m.getChildListingCmd(file.Path))
+		}
+	}
+
+	return tea.Batch(cmds...)
+}
+
+// handleChildListing stores the contents of an expanded directory, expanding
+// its subdirectories further when an expand all is in progress.
+func (m *Model) handleChildListing(msg getChildListingMsg) tea.Cmd {
+	var cmds []tea.Cmd
+
+	if _, ok := m.expanded[msg.path]; !ok {
+		return nil
+	}
+
+	m.annotateGitStatus(msg.files)
+	m.sortFiles(msg.files)
+	m.children[msg.path] = msg.files
+	_ = m.applyFilter()
+	m.restorePendingSelection()
+
+	cmds = append(cmds, m.loadSortMetadataCmd(msg.files))
+
+	for _, file := range m.files {
+		if file.Path != msg.path {
+			continue
+		}
+
+		for _, child := range msg.files {
+			_, loaded := m.children[child.Path]
+
+			switch {
+			case m.isExpanded(child) && !loaded:
+				cmds = append(cmds, m.getChildListingCmd(child.Path))
+			case child.IsDirectory && !m.isExpanded(child) && m.expandDepth > file.Depth+1:
+				cmds = append(cmds, m.expandDirectoryCmd(child))
+			}
+		}
+
+		break
+	}
+
+	return tea.Batch(cmds...)
+}
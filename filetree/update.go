@@ -1,21 +1,40 @@
 package filetree
 
 import (
+	"fmt"
 	"path/filepath"
+	"unicode"
 
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
 	"github.com/mistakenelf/fm/filesystem"
+	"github.com/mistakenelf/fm/internal/views"
 	"github.com/mistakenelf/fm/polish"
 )
 
+// Update handles messages and then loads the metadata of the rows in view.
 func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	m, cmd := m.update(msg)
+
+	return m, tea.Batch(cmd, m.loadVisibleMetadataCmd())
+}
+
+func (m Model) update(msg tea.Msg) (Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
+	// Messages addressed to a specific filetree are still handled while it is
+	// disabled so that its listing stays up to date.
 	if m.Disabled {
-		return m, nil
+		switch msg.(type) {
+		case getDirectoryListingMsg, getChildListingMsg, moveDirectoryItemMsg,
+			createFileMsg, createDirectoryMsg, renameDirectoryItemMsg, directoryChangedMsg,
+			directoryBatchMsg, metadataMsg, directorySizeMsg, spinner.TickMsg, gitStatusMsg:
+		default:
+			return m, nil
+		}
 	}
 
 	switch msg := msg.(type) {
@@ -33,38 +52,221 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	case statusMessageTimeoutMsg:
 		m.StatusMessage = ""
 	case moveDirectoryItemMsg:
+		if msg.id != m.id {
+			return m, nil
+		}
+
 		m.State = IdleState
 
 		return m, m.GetDirectoryListingCmd(m.CurrentDirectory)
+	case pasteMsg:
+		if msg.id != m.id {
+			return m, nil
+		}
+
+		done := "Pasted"
+		if msg.cut {
+			done = "Moved"
+		}
+
+		items := "items"
+		if msg.count == 1 {
+			items = "item"
+		}
+
+		return m, tea.Batch(
+			m.NewStatusMessageCmd(fmt.Sprintf("%s %d %s", done, msg.count, items)),
+			m.GetDirectoryListingCmd(m.CurrentDirectory),
+		)
 	case copyToClipboardMsg:
 		cmds = append(cmds, m.NewStatusMessageCmd(
 			lipgloss.NewStyle().
 				Bold(true).
 				Render(string(msg))))
+	case gitActionMsg:
+		cmds = append(cmds, m.NewStatusMessageCmd(
+			lipgloss.NewStyle().
+				Bold(true).
+				Render(string(msg))))
+	case journalMsg:
+		cmds = append(cmds, m.NewStatusMessageCmd(
+			lipgloss.NewStyle().
+				Bold(true).
+				Render(string(msg))))
 	case createFileMsg:
+		if msg.id != m.id {
+			return m, nil
+		}
+
 		m.State = IdleState
 
 		return m, m.GetDirectoryListingCmd(m.CurrentDirectory)
 	case createDirectoryMsg:
+		if msg.id != m.id {
+			return m, nil
+		}
+
 		m.State = IdleState
 
 		return m, m.GetDirectoryListingCmd(m.CurrentDirectory)
 	case renameDirectoryItemMsg:
+		if msg.id != m.id {
+			return m, nil
+		}
+
 		m.State = IdleState
 
 		return m, m.GetDirectoryListingCmd(m.CurrentDirectory)
 	case getDirectoryListingMsg:
+		if msg.id != m.id {
+			return m, nil
+		}
+
 		if msg.files != nil {
-			m.files = msg.files
+			m.unfilteredFiles = msg.files
 		} else {
-			m.files = make([]DirectoryItem, 0)
+			m.unfilteredFiles = make([]DirectoryItem, 0)
+		}
+
+		previousDirectory := m.CurrentDirectory
+		directoryChanged := msg.workingDirectory != previousDirectory
+
+		if previousDirectory != "" {
+			m.positions[previousDirectory] = m.getViewPosition()
+		}
+
+		if directoryChanged && m.unsavedView {
+			cmds = append(cmds, m.saveViewsCmd())
+		}
+
+		if directoryChanged || msg.historyIndex != noHistoryIndex {
+			m.updateHistory(msg)
 		}
 
+		m.stopListing()
+		m.cancelListing = msg.cancel
+		m.listingBatches = msg.batches
+
+		// Marks are pruned once the whole listing has been read.
+		if directoryChanged {
+			m.ClearMarks()
+			m.clearGitStatus(msg.workingDirectory)
+			m.stopSizes()
+			m.directorySizes = make(map[string]string)
+			m.filter = ""
+		}
+
+		if msg.view != nil {
+			m.applyViewSettings(*msg.view)
+		}
+
+		m.annotateGitStatus(m.unfilteredFiles)
+		m.sortFiles(m.unfilteredFiles)
+
+		files, err := m.visibleFiles()
+		if err != nil {
+			files = m.unfilteredFiles
+		}
+
+		m.files = files
+
 		m.CurrentDirectory = msg.workingDirectory
 		m.Cursor = 0
 		m.min = 0
-		m.max = max(m.max, m.height-1)
+		m.max = m.height - 1
+
+		// The item to select may only arrive in a later batch, in which case
+		// it is selected once it does. Refreshing the directory keeps the
+		// position it was just saved with.
+		var position viewPosition
+
+		switch {
+		case msg.selectedName != "":
+			position.selected = filepath.Join(msg.workingDirectory, msg.selectedName)
+		case msg.historyIndex != noHistoryIndex:
+			position = m.historyPosition()
+		default:
+			position = m.positions[msg.workingDirectory]
+
+			// Keep the cursor on the directory just left when going up to its
+			// parent.
+			if directoryChanged && filepath.Dir(previousDirectory) == msg.workingDirectory {
+				position.selected = previousDirectory
+			}
+		}
+
+		if position.selected != "" && !m.restoreViewPosition(position) {
+			m.pendingSelection = position
+		}
+
+		cmds = append(
+			cmds,
+			waitForBatchCmd(m.id, msg.workingDirectory, msg.batches),
+			m.refreshExpandedCmd(),
+			m.watchCmd(),
+			m.loadGitStatusCmd(),
+			m.loadSortMetadataCmd(m.allFiles()),
+		)
+	case directoryBatchMsg:
+		if msg.id != m.id {
+			return m, nil
+		}
+
+		cmds = append(cmds, m.handleDirectoryBatch(msg))
+	case metadataMsg:
+		if msg.id != m.id {
+			return m, nil
+		}
+
+		m.handleMetadata(msg)
+	case directorySizeMsg:
+		if msg.id != m.id {
+			return m, nil
+		}
+
+		cmds = append(cmds, m.handleDirectorySize(msg))
+	case gitStatusMsg:
+		if msg.id != m.id {
+			return m, nil
+		}
+
+		cmds = append(cmds, m.handleGitStatus(msg))
+	case spinner.TickMsg:
+		// The spinner stops once every directory size has been calculated.
+		if len(m.sizesPending) == 0 {
+			return m, nil
+		}
+
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+
+		return m, cmd
+	case getChildListingMsg:
+		if msg.id != m.id {
+			return m, nil
+		}
+
+		cmds = append(cmds, m.handleChildListing(msg), m.watchCmd())
+	case directoryChangedMsg:
+		if msg.id != m.id || m.watcher == nil {
+			return m, nil
+		}
+
+		cmd := m.refreshChangedCmd(msg)
+
+		return m, tea.Batch(cmd, waitForChangesCmd(m.id, m.watcher))
 	case tea.KeyMsg:
+		count := m.count
+		m.count = 0
+
+		// In the tree view a numeric prefix sets the depth used by
+		// expand all and collapse all.
+		if m.treeView && m.State == IdleState && len(msg.Runes) == 1 && unicode.IsDigit(msg.Runes[0]) {
+			m.count = count*10 + int(msg.Runes[0]-'0')
+
+			return m, nil
+		}
+
 		switch {
 		case key.Matches(msg, m.keyMap.Down):
 			if m.State != IdleState {
@@ -165,9 +367,19 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				return m, nil
 			}
 
-			m.showHidden = !m.showHidden
+			showHidden := !m.showHidden
+			cmd := m.changeViewCmd(func(settings *views.Settings) { settings.ShowHidden = showHidden })
+
+			return m, tea.Batch(cmd, m.GetDirectoryListingCmd(m.CurrentDirectory))
+		case key.Matches(msg, m.keyMap.ToggleIgnored):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			hideIgnored := !m.hideIgnored
+			cmd := m.changeViewCmd(func(settings *views.Settings) { settings.HideIgnored = hideIgnored })
 
-			return m, m.GetDirectoryListingCmd(m.CurrentDirectory)
+			return m, tea.Batch(cmd, m.GetDirectoryListingCmd(m.CurrentDirectory))
 		case key.Matches(msg, m.keyMap.OpenDirectory):
 			if m.State != IdleState {
 				return m, nil
@@ -184,19 +396,34 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			return m, m.GetDirectoryListingCmd(
 				filepath.Dir(m.CurrentDirectory),
 			)
+		case key.Matches(msg, m.keyMap.GoBack):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			return m, m.GoBackCmd()
+		case key.Matches(msg, m.keyMap.GoForward):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			return m, m.GoForwardCmd()
 		case key.Matches(msg, m.keyMap.CopyPathToClipboard):
 			if m.State != IdleState {
 				return m, nil
 			}
 
-			return m, copyToClipboardCmd(m.files[m.Cursor].Path)
+			return m, copyToClipboardCmd(m.operationItems([]DirectoryItem{m.GetSelectedItem()})[0].Path)
 		case key.Matches(msg, m.keyMap.CopyDirectoryItem):
 			if m.State != IdleState {
 				return m, nil
 			}
 
+			items := m.GetSelectedItems()
+			m.ClearMarks()
+
 			return m, tea.Sequence(
-				copyDirectoryItemCmd(m.files[m.Cursor].Path, m.files[m.Cursor].IsDirectory),
+				copyDirectoryItemsCmd(m.operationItems(items), m.journal),
 				m.GetDirectoryListingCmd(m.CurrentDirectory),
 			)
 		case key.Matches(msg, m.keyMap.DeleteDirectoryItem):
@@ -204,46 +431,67 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				return m, nil
 			}
 
+			items := m.GetSelectedItems()
+			m.ClearMarks()
+
 			return m, tea.Sequence(
-				deleteDirectoryItemCmd(m.files[m.Cursor].Path, m.files[m.Cursor].IsDirectory),
+				trashDirectoryItemsCmd(m.operationItems(items), m.journal),
 				m.GetDirectoryListingCmd(m.CurrentDirectory),
 			)
-		case key.Matches(msg, m.keyMap.ZipDirectoryItem):
+		case key.Matches(msg, m.keyMap.PermanentlyDelete):
 			if m.State != IdleState {
 				return m, nil
 			}
 
+			items := m.GetSelectedItems()
+			m.ClearMarks()
+
 			return m, tea.Sequence(
-				zipDirectoryCmd(m.files[m.Cursor].Path),
+				deleteDirectoryItemsCmd(m.operationItems(items)),
 				m.GetDirectoryListingCmd(m.CurrentDirectory),
 			)
-		case key.Matches(msg, m.keyMap.UnzipDirectoryItem):
+		case key.Matches(msg, m.keyMap.ZipDirectoryItem):
 			if m.State != IdleState {
 				return m, nil
 			}
 
+			items := m.GetSelectedItems()
+			m.ClearMarks()
+
 			return m, tea.Sequence(
-				unzipDirectoryCmd(m.files[m.Cursor].Name),
+				zipDirectoryItemsCmd(m.operationItems(items)),
 				m.GetDirectoryListingCmd(m.CurrentDirectory),
 			)
+		case key.Matches(msg, m.keyMap.UnzipDirectoryItem):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			return m, m.UnzipSelectedItemsCmd(nil)
 		case key.Matches(msg, m.keyMap.ShowDirectoriesOnly):
 			if m.State != IdleState {
 				return m, nil
 			}
 
-			m.showDirectoriesOnly = !m.showDirectoriesOnly
-			m.showFilesOnly = false
+			showDirectoriesOnly := !m.showDirectoriesOnly
+			cmd := m.changeViewCmd(func(settings *views.Settings) {
+				settings.ShowDirectoriesOnly = showDirectoriesOnly
+				settings.ShowFilesOnly = false
+			})
 
-			return m, m.GetDirectoryListingCmd(m.CurrentDirectory)
+			return m, tea.Batch(cmd, m.GetDirectoryListingCmd(m.CurrentDirectory))
 		case key.Matches(msg, m.keyMap.ShowFilesOnly):
 			if m.State != IdleState {
 				return m, nil
 			}
 
-			m.showFilesOnly = !m.showFilesOnly
-			m.showDirectoriesOnly = false
+			showFilesOnly := !m.showFilesOnly
+			cmd := m.changeViewCmd(func(settings *views.Settings) {
+				settings.ShowFilesOnly = showFilesOnly
+				settings.ShowDirectoriesOnly = false
+			})
 
-			return m, m.GetDirectoryListingCmd(m.CurrentDirectory)
+			return m, tea.Batch(cmd, m.GetDirectoryListingCmd(m.CurrentDirectory))
 		case key.Matches(msg, m.keyMap.WriteSelectionPath):
 			if m.State != IdleState {
 				return m, nil
@@ -269,6 +517,40 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			m.State = CreateFileState
 
 			return m, nil
+		case key.Matches(msg, m.keyMap.CreateSymlink):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			m.State = CreateSymlinkState
+
+			return m, nil
+		case key.Matches(msg, m.keyMap.CreateHardlink):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			m.State = CreateHardlinkState
+
+			return m, nil
+		case key.Matches(msg, m.keyMap.ToggleFollowLinks):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			return m, m.ToggleFollowSymlinksCmd()
+		case key.Matches(msg, m.keyMap.Undo):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			return m, tea.Sequence(m.UndoCmd(), m.GetDirectoryListingCmd(m.CurrentDirectory))
+		case key.Matches(msg, m.keyMap.Redo):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			return m, tea.Sequence(m.RedoCmd(), m.GetDirectoryListingCmd(m.CurrentDirectory))
 		case key.Matches(msg, m.keyMap.CreateDirectory):
 			if m.State != IdleState {
 				return m, nil
@@ -283,6 +565,137 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			}
 
 			m.State = RenameState
+		case key.Matches(msg, m.keyMap.MarkByGlob):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			m.State = MarkByGlobState
+
+			return m, nil
+		case key.Matches(msg, m.keyMap.AddBookmark):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			m.State = AddBookmarkState
+
+			return m, nil
+		case key.Matches(msg, m.keyMap.JumpToFrecent):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			m.State = JumpState
+
+			return m, nil
+		case key.Matches(msg, m.keyMap.CycleSortOrder):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			cmds = append(cmds, m.CycleSortOrder())
+		case key.Matches(msg, m.keyMap.ReverseSort):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			cmds = append(cmds, m.ToggleSortReversed())
+		case key.Matches(msg, m.keyMap.DirectoriesFirst):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			cmds = append(cmds, m.ToggleDirectoriesFirst())
+		case key.Matches(msg, m.keyMap.ToggleDetails):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			m.showDetails = !m.showDetails
+		case key.Matches(msg, m.keyMap.GitStage):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			return m, m.StageCmd()
+		case key.Matches(msg, m.keyMap.GitUnstage):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			return m, m.UnstageCmd()
+		case key.Matches(msg, m.keyMap.GitDiscard):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			return m, m.DiscardCmd()
+		case key.Matches(msg, m.keyMap.ToggleTreeView):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			return m, m.ToggleTreeViewCmd()
+		case key.Matches(msg, m.keyMap.ToggleExpand):
+			if m.State != IdleState || !m.treeView {
+				return m, nil
+			}
+
+			return m, m.ToggleExpandCmd()
+		case key.Matches(msg, m.keyMap.ExpandAll):
+			if m.State != IdleState || !m.treeView {
+				return m, nil
+			}
+
+			return m, m.ExpandAllCmd(max(count, 1))
+		case key.Matches(msg, m.keyMap.CollapseAll):
+			if m.State != IdleState || !m.treeView {
+				return m, nil
+			}
+
+			m.CollapseAll(count)
+		case key.Matches(msg, m.keyMap.Filter):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			m.State = FilterState
+
+			return m, nil
+		case key.Matches(msg, m.keyMap.ToggleMark):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			m.ToggleMark()
+
+			if m.Cursor < len(m.files)-1 {
+				m.Cursor++
+
+				if m.Cursor > m.max {
+					m.min++
+					m.max++
+				}
+			}
+		case key.Matches(msg, m.keyMap.MarkAll):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			m.MarkAll()
+		case key.Matches(msg, m.keyMap.InvertMarks):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			m.InvertMarks()
+		case key.Matches(msg, m.keyMap.ClearMarks):
+			if m.State != IdleState {
+				return m, nil
+			}
+
+			m.ClearMarks()
 		}
 	}
 
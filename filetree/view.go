@@ -1,13 +1,194 @@
 package filetree
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/exp/term/ansi"
 
+	"github.com/mistakenelf/fm/filesystem"
 	"github.com/mistakenelf/fm/icons"
+	"github.com/mistakenelf/fm/internal/gitrepo"
+	"github.com/mistakenelf/fm/polish"
 )
 
+const (
+	minNameWidth     = 16
+	permissionsWidth = 10
+	sizeWidth        = 7
+	modTimeWidth     = 12
+	columnGap        = 2
+	recentFileWindow = 6 * 30 * 24 * time.Hour
+)
+
+// detailColumns holds which columns of the details view fit in the pane.
+type detailColumns struct {
+	permissions bool
+	ownership   bool
+	size        bool
+	modTime     bool
+	ownerWidth  int
+	nameWidth   int
+}
+
+// getDetailColumns works out which detail columns fit in the width of the
+// pane, dropping the least important ones first when it is narrow.
+func (m Model) getDetailColumns() detailColumns {
+	columns := detailColumns{nameWidth: m.width}
+
+	if !m.showDetails {
+		return columns
+	}
+
+	for i := m.min; i <= m.max && i < len(m.files); i++ {
+		if m.files[i].FileInfo == nil {
+			continue
+		}
+
+		owner, group := filesystem.GetOwnership(m.files[i].FileInfo)
+		if owner != "" {
+			columns.ownerWidth = max(columns.ownerWidth, len(owner)+len(group)+1)
+		}
+	}
+
+	// Columns in order of importance, the last ones are dropped first.
+	candidates := []struct {
+		width  int
+		enable func()
+	}{
+		{sizeWidth, func() { columns.size = true }},
+		{modTimeWidth, func() { columns.modTime = true }},
+		{permissionsWidth, func() { columns.permissions = true }},
+		{columns.ownerWidth, func() { columns.ownership = true }},
+	}
+
+	for _, candidate := range candidates {
+		if candidate.width == 0 {
+			continue
+		}
+
+		if columns.nameWidth-candidate.width-columnGap < minNameWidth {
+			break
+		}
+
+		columns.nameWidth -= candidate.width + columnGap
+		candidate.enable()
+	}
+
+	return columns
+}
+
+// formatModTime formats a modification time the same way ls -l does.
+func formatModTime(modTime time.Time) string {
+	if time.Since(modTime) > recentFileWindow || modTime.After(time.Now()) {
+		return modTime.Format("Jan _2  2006")
+	}
+
+	return modTime.Format("Jan _2 15:04")
+}
+
+// renderDetails renders the detail columns for a directory item.
+func (m Model) renderDetails(file DirectoryItem, columns detailColumns) string {
+	var details []string
+
+	if columns.permissions {
+		details = append(details, fmt.Sprintf("%-*s", permissionsWidth, file.Details))
+	}
+
+	if columns.ownership {
+		ownership := ""
+
+		if file.FileInfo != nil {
+			owner, group := filesystem.GetOwnership(file.FileInfo)
+			ownership = owner + " " + group
+		}
+
+		details = append(details, fmt.Sprintf("%-*s", columns.ownerWidth, ownership))
+	}
+
+	if columns.size {
+		size := file.FileSize
+
+		if m.isSizePending(file) {
+			size = m.spinner.View()
+		}
+
+		details = append(details, strings.Repeat(" ", max(sizeWidth-ansi.StringWidth(size), 0))+size)
+	}
+
+	if columns.modTime {
+		modTime := ""
+
+		if file.FileInfo != nil {
+			modTime = formatModTime(file.FileInfo.ModTime())
+		}
+
+		details = append(details, fmt.Sprintf("%-*s", modTimeWidth, modTime))
+	}
+
+	if len(details) == 0 {
+		return ""
+	}
+
+	gap := strings.Repeat(" ", columnGap)
+
+	return gap + strings.Join(details, gap)
+}
+
+// rowStyles holds the styles used to render rows so that they are not
+// rebuilt for every row on every render.
+type rowStyles struct {
+	marker          lipgloss.Style
+	guide           lipgloss.Style
+	selected        lipgloss.Style
+	unselected      lipgloss.Style
+	inactive        lipgloss.Style
+	marked          lipgloss.Style
+	brokenLink      lipgloss.Style
+	details         lipgloss.Style
+	selectedDetails lipgloss.Style
+	icons           map[string]lipgloss.Style
+	gitStatuses     map[gitrepo.FileStatus]lipgloss.Style
+}
+
+// newRowStyles builds the row styles for the colors provided.
+func newRowStyles(selectedItemColor, unselectedItemColor, inactiveItemColor lipgloss.AdaptiveColor) rowStyles {
+	bold := lipgloss.NewStyle().Bold(true)
+
+	return rowStyles{
+		marker:          bold.Foreground(polish.Colors.Yellow500),
+		guide:           lipgloss.NewStyle().Foreground(inactiveItemColor),
+		selected:        bold.Foreground(selectedItemColor),
+		unselected:      bold.Foreground(unselectedItemColor),
+		inactive:        bold.Foreground(inactiveItemColor),
+		marked:          bold.Foreground(polish.Colors.Yellow500),
+		brokenLink:      bold.Foreground(polish.Colors.Red600).Strikethrough(true),
+		details:         lipgloss.NewStyle().Foreground(inactiveItemColor),
+		selectedDetails: lipgloss.NewStyle().Foreground(selectedItemColor),
+		icons:           make(map[string]lipgloss.Style),
+		gitStatuses: map[gitrepo.FileStatus]lipgloss.Style{
+			gitrepo.Ignored:    lipgloss.NewStyle().Foreground(inactiveItemColor),
+			gitrepo.Untracked:  bold.Foreground(polish.Colors.Blue500),
+			gitrepo.Staged:     bold.Foreground(polish.Colors.Green600),
+			gitrepo.Modified:   bold.Foreground(polish.Colors.Yellow500),
+			gitrepo.Conflicted: bold.Foreground(polish.Colors.Red600),
+		},
+	}
+}
+
+// iconStyle returns the style for an icon of the given color, caching it.
+func (s rowStyles) iconStyle(color string) lipgloss.Style {
+	style, ok := s.icons[color]
+	if !ok {
+		style = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(color))
+		s.icons[color] = style
+	}
+
+	return style
+}
+
 func (m Model) View() string {
 	var fileList strings.Builder
 
@@ -15,74 +196,97 @@ func (m Model) View() string {
 		return "Error: " + m.err.Error() + "\n"
 	}
 
-	for i, file := range m.files {
-		if i < m.min || i > m.max {
-			continue
-		}
+	columns := m.getDetailColumns()
+	hasMarks := m.GetTotalMarked() > 0
+
+	// Only the rows in view are rendered so that the cost of rendering does
+	// not grow with the size of the directory.
+	for i := max(m.min, 0); i <= m.max && i < len(m.files); i++ {
+		file := m.files[i]
+
+		var textStyle, iconStyle lipgloss.Style
+		icon := icons.GetElementIcon(file.Name, file.IsDirectory)
 
 		switch {
-		case m.Disabled:
-			fallthrough
 		case i == m.Cursor && !m.Disabled:
-			textColor := m.inactiveItemColor
+			textStyle = m.styles.selected
+			iconStyle = textStyle
+		case m.Disabled, i == m.Cursor:
+			textStyle = m.styles.inactive
+			iconStyle = textStyle
+		default:
+			textStyle = m.styles.unselected
+			iconStyle = m.styles.iconStyle(icon.Color)
 
-			if i == m.Cursor && !m.Disabled {
-				textColor = m.selectedItemColor
+			switch {
+			case m.IsMarked(file):
+				textStyle = m.styles.marked
+			case file.IsBrokenLink:
+				textStyle = m.styles.brokenLink
+				iconStyle = textStyle
 			}
+		}
 
-			if m.showIcons {
-				icon := icons.GetElementIcon(file.Name, file.IsDirectory)
-
-				fileList.WriteString(
-					lipgloss.NewStyle().
-						Bold(true).
-						Foreground(textColor).
-						Render(icon.Icon) + " ",
-				)
-
-				fileList.WriteString(
-					lipgloss.NewStyle().
-						Bold(true).
-						Foreground(textColor).
-						Render(file.Name) + "\n",
-				)
-			} else {
-				fileList.WriteString(
-					lipgloss.NewStyle().
-						Bold(true).
-						Foreground(textColor).
-						Render(file.Name) + "\n",
-				)
-			}
+		nameWidth := columns.nameWidth
+
+		switch {
+		case m.IsMarked(file):
+			fileList.WriteString(m.styles.marker.Render("+") + " ")
 
-		case i != m.Cursor && !m.Disabled:
-			textColor := m.unselectedItemColor
-
-			if m.showIcons {
-				icon := icons.GetElementIcon(file.Name, file.IsDirectory)
-
-				fileList.WriteString(
-					lipgloss.NewStyle().
-						Bold(true).
-						Foreground(lipgloss.Color(icon.Color)).
-						Render(icon.Icon) + " ",
-				)
-
-				fileList.WriteString(
-					lipgloss.NewStyle().
-						Bold(true).
-						Foreground(textColor).
-						Render(file.Name) + "\n",
-				)
-			} else {
-				fileList.WriteString(
-					lipgloss.NewStyle().
-						Bold(true).
-						Foreground(textColor).
-						Render(file.Name) + "\n",
-				)
+			nameWidth -= 2
+		case hasMarks:
+			fileList.WriteString("  ")
+
+			nameWidth -= 2
+		}
+
+		if file.guide != "" {
+			fileList.WriteString(m.styles.guide.Render(file.guide))
+
+			nameWidth -= ansi.StringWidth(file.guide)
+		}
+
+		// Items within a git work tree show their status in a column of its own.
+		if m.gitStatus != nil {
+			fileList.WriteString(m.styles.gitStatuses[file.GitStatus].Render(file.GitStatus.Symbol()) + " ")
+
+			nameWidth -= 2
+		}
+
+		if m.showIcons {
+			fileList.WriteString(iconStyle.Render(icon.Icon) + " ")
+
+			nameWidth -= ansi.StringWidth(icon.Icon) + 1
+		}
+
+		name := file.Name
+
+		// Broken links always show where they point to.
+		if (m.showDetails || file.IsBrokenLink) && file.LinkTarget != "" {
+			name += " -> " + file.LinkTarget
+		}
+
+		// Long names are truncated rather than wrapped so that narrow panes
+		// keep one row per item.
+		name = ansi.Truncate(name, max(nameWidth, 0), "…")
+
+		if m.showDetails {
+			name += strings.Repeat(" ", max(nameWidth-ansi.StringWidth(name), 0))
+		}
+
+		fileList.WriteString(textStyle.Render(name))
+
+		if m.showDetails {
+			detailsStyle := m.styles.details
+
+			if i == m.Cursor && !m.Disabled {
+				detailsStyle = m.styles.selectedDetails
 			}
+
+			fileList.WriteString(detailsStyle.Render(m.renderDetails(file, columns)))
 		}
+
+		fileList.WriteString("\n")
 	}
 
 	return lipgloss.NewStyle().
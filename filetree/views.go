@@ -0,0 +1,122 @@
+package filetree
+
+import (
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/mistakenelf/fm/internal/views"
+)
+
+// viewSettings returns the current settings of how the listing is shown.
+func (m Model) viewSettings() views.Settings {
+	return views.Settings{
+		ShowHidden:          m.showHidden,
+		HideIgnored:         m.hideIgnored,
+		ShowDirectoriesOnly: m.showDirectoriesOnly,
+		ShowFilesOnly:       m.showFilesOnly,
+		SortOrder:           int(m.sortOrder),
+		SortReversed:        m.sortReversed,
+		DirectoriesFirst:    m.directoriesFirst,
+		Filter:              m.filter,
+		FilterMode:          int(m.filterMode),
+	}
+}
+
+// applyViewSettings changes the settings of how the listing is shown without
+// reading the directory again.
+func (m *Model) applyViewSettings(settings views.Settings) {
+	m.showHidden = settings.ShowHidden
+	m.hideIgnored = settings.HideIgnored
+	m.showDirectoriesOnly = settings.ShowDirectoriesOnly
+	m.showFilesOnly = settings.ShowFilesOnly
+	m.sortOrder = SortOrder(settings.SortOrder)
+	m.sortReversed = settings.SortReversed
+	m.directoriesFirst = settings.DirectoriesFirst
+	m.filter = settings.Filter
+	m.filterMode = FilterMode(settings.FilterMode)
+}
+
+// directoryViewSettings returns the settings a directory is listed with, which
+// are those of the session unless others were remembered for it. Nil is
+// returned when settings are not remembered at all.
+func (m Model) directoryViewSettings(directory string) *views.Settings {
+	if m.views == nil {
+		return nil
+	}
+
+	settings, ok := m.views.Get(directory)
+	if !ok {
+		settings = m.sessionView
+	}
+
+	return &settings
+}
+
+// rememberView stores the view settings of the current directory when they
+// differ from those of the session, reporting whether they changed. They are
+// saved once the directory is left.
+func (m *Model) rememberView() bool {
+	return m.rememberViewAgainst(m.sessionView)
+}
+
+// rememberViewAgainst stores the view settings of the current directory when
+// they differ from base, reporting whether they changed.
+func (m *Model) rememberViewAgainst(base views.Settings) bool {
+	if m.views == nil || m.CurrentDirectory == "" {
+		return false
+	}
+
+	if !m.views.Set(m.CurrentDirectory, m.viewSettings(), base) {
+		return false
+	}
+
+	m.unsavedView = true
+
+	return true
+}
+
+// changeViewCmd makes an explicit change to the view settings, carrying it
+// over to the directories of the session which have no settings of their own.
+// The current directory keeps it as its own when it differs from what the
+// session had, and the settings are saved.
+func (m *Model) changeViewCmd(change func(*views.Settings)) tea.Cmd {
+	previous := m.sessionView
+	settings := m.viewSettings()
+
+	change(&settings)
+	change(&m.sessionView)
+	m.applyViewSettings(settings)
+
+	if !m.rememberViewAgainst(previous) {
+		return nil
+	}
+
+	return m.saveViewsCmd()
+}
+
+// saveViewsCmd writes the remembered view settings in the background.
+func (m *Model) saveViewsCmd() tea.Cmd {
+	store := m.views
+	m.unsavedView = false
+
+	return func() tea.Msg {
+		if err := store.Save(); err != nil {
+			return errorMsg(err.Error())
+		}
+
+		return nil
+	}
+}
+
+// isHiddenByPattern reports whether a name matches one of the patterns which
+// are always hidden.
+func (m Model) isHiddenByPattern(name string) bool {
+	for _, pattern := range m.hidePatterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,322 @@
+package filetree
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/mistakenelf/fm/filesystem"
+)
+
+// watchDebounce is how long the watcher waits for a burst of events to settle
+// before asking for a refresh.
+const watchDebounce = 200 * time.Millisecond
+
+// maxChangedPaths is how many changed paths are read again one by one before
+// the whole directory is read again instead.
+const maxChangedPaths = 256
+
+// directoryChangedMsg reports the paths which changed within the watched
+// directories, or that everything may have when all is set.
+type directoryChangedMsg struct {
+	id    int
+	paths []string
+	all   bool
+}
+
+// directoryWatcher watches the directories shown by a filetree and reports
+// debounced changes to them.
+type directoryWatcher struct {
+	fsWatcher *fsnotify.Watcher
+	changes   chan struct{}
+	paths     map[string]struct{}
+
+	mu         sync.Mutex
+	changed    map[string]struct{}
+	overflowed bool
+}
+
+// newDirectoryWatcher starts watching for changes, returning nil when file
+// watching is not available.
+func newDirectoryWatcher() *directoryWatcher {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil
+	}
+
+	w := &directoryWatcher{
+		fsWatcher: fsWatcher,
+		changes:   make(chan struct{}, 1),
+		paths:     make(map[string]struct{}),
+		changed:   make(map[string]struct{}),
+	}
+
+	go w.run()
+
+	return w
+}
+
+// run debounces the events of the watcher until it is closed.
+func (w *directoryWatcher) run() {
+	defer close(w.changes)
+
+	var debounce <-chan time.Time
+
+	for {
+		select {
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return
+			}
+
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			w.addChange(event.Name)
+
+			if debounce == nil {
+				debounce = time.After(watchDebounce)
+			}
+		case err, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+
+			// Events were dropped, so which paths changed isn't known.
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				w.addChange("")
+
+				if debounce == nil {
+					debounce = time.After(watchDebounce)
+				}
+			}
+		case <-debounce:
+			debounce = nil
+
+			select {
+			case w.changes <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// addChange records a path which changed, or that any may have when it is
+// empty.
+func (w *directoryWatcher) addChange(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if path == "" || len(w.changed) >= maxChangedPaths {
+		w.overflowed = true
+
+		return
+	}
+
+	w.changed[path] = struct{}{}
+}
+
+// takeChanges returns the paths which changed since it was last called, and
+// whether too many did to tell which.
+func (w *directoryWatcher) takeChanges() ([]string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	paths := make([]string, 0, len(w.changed))
+
+	for path := range w.changed {
+		paths = append(paths, path)
+	}
+
+	all := w.overflowed
+	w.changed = make(map[string]struct{})
+	w.overflowed = false
+
+	return paths, all
+}
+
+// setPaths watches exactly the directories provided.
+func (w *directoryWatcher) setPaths(paths []string) {
+	wanted := make(map[string]struct{}, len(paths))
+
+	for _, path := range paths {
+		wanted[path] = struct{}{}
+
+		if _, ok := w.paths[path]; ok {
+			continue
+		}
+
+		if err := w.fsWatcher.Add(path); err == nil {
+			w.paths[path] = struct{}{}
+		}
+	}
+
+	for path := range w.paths {
+		if _, ok := wanted[path]; !ok {
+			_ = w.fsWatcher.Remove(path)
+			delete(w.paths, path)
+		}
+	}
+}
+
+// close stops watching.
+func (w *directoryWatcher) close() {
+	_ = w.fsWatcher.Close()
+}
+
+// waitForChangesCmd waits for the next change to the watched directories.
+func waitForChangesCmd(id int, w *directoryWatcher) tea.Cmd {
+	return func() tea.Msg {
+		if _, ok := <-w.changes; !ok {
+			return nil
+		}
+
+		paths, all := w.takeChanges()
+
+		return directoryChangedMsg{id: id, paths: paths, all: all}
+	}
+}
+
+// watchCmd updates the watched directories to the current directory and the
+// directories expanded in the tree view, starting the watcher if needed.
+func (m *Model) watchCmd() tea.Cmd {
+	var cmd tea.Cmd
+
+	if m.CurrentDirectory == "" {
+		return nil
+	}
+
+	if m.watcher == nil {
+		m.watcher = newDirectoryWatcher()
+		if m.watcher == nil {
+			return nil
+		}
+
+		cmd = waitForChangesCmd(m.id, m.watcher)
+	}
+
+	paths := []string{m.CurrentDirectory}
+
+	if m.treeView {
+		for _, file := range m.files {
+			if m.isExpanded(file) {
+				paths = append(paths, file.Path)
+			}
+		}
+	}
+
+	m.watcher.setPaths(paths)
+
+	return cmd
+}
+
+// Close stops watching the directories shown by the filetree and stops
+// reading the listing and calculating the directory sizes being loaded.
+func (m *Model) Close() {
+	m.stopListing()
+	m.stopSizes()
+
+	if m.watcher != nil {
+		m.watcher.close()
+		m.watcher = nil
+	}
+}
+
+// refreshChangedCmd brings the listing up to date with the paths which
+// changed. Only those items are read again, keeping the metadata, git status
+// and sizes of the rest, and expanded directories which changed are listed
+// again. The whole directory is read again when too much changed or it is
+// still being listed.
+func (m *Model) refreshChangedCmd(msg directoryChangedMsg) tea.Cmd {
+	if msg.all || m.IsLoading() {
+		return m.GetDirectoryListingCmd(m.CurrentDirectory)
+	}
+
+	var (
+		cmds        []tea.Cmd
+		items       []DirectoryItem
+		directories []string
+	)
+
+	changed := make(map[string]struct{}, len(msg.paths))
+	expanded := make(map[string]struct{})
+	ignore := m.ignoreMatcher()
+
+	for _, path := range msg.paths {
+		directory := filepath.Dir(path)
+
+		switch {
+		case path == m.CurrentDirectory:
+			return m.GetDirectoryListingCmd(m.CurrentDirectory)
+		case directory == m.CurrentDirectory:
+			changed[path] = struct{}{}
+			delete(m.directorySizes, path)
+
+			item, ok := m.readItem(ignore, path)
+			if !ok {
+				delete(m.children, path)
+				delete(m.expanded, path)
+
+				continue
+			}
+
+			items = append(items, item)
+
+			if item.IsDirectory {
+				directories = append(directories, item.Path)
+			}
+		default:
+			if _, ok := m.children[directory]; ok {
+				expanded[directory] = struct{}{}
+			}
+		}
+	}
+
+	if len(changed) > 0 {
+		unchanged := make([]DirectoryItem, 0, len(m.unfilteredFiles))
+
+		for _, file := range m.unfilteredFiles {
+			if _, ok := changed[file.Path]; !ok {
+				unchanged = append(unchanged, file)
+			}
+		}
+
+		m.annotateGitStatus(items)
+		m.sortFiles(items)
+		m.unfilteredFiles = m.mergeSortedFiles(unchanged, items)
+		_ = m.applyFilter()
+		m.pruneMarks()
+
+		cmds = append(cmds, m.loadGitStatusCmd(), m.calculateChangedSizesCmd(directories))
+	}
+
+	for directory := range expanded {
+		cmds = append(cmds, m.getChildListingCmd(directory))
+	}
+
+	return tea.Batch(cmds...)
+}
+
+// readItem reads the item at a path of the current directory again along
+// with its metadata, reporting whether it exists and is shown with the
+// current settings.
+func (m Model) readItem(ignore *filesystem.IgnoreMatcher, path string) (DirectoryItem, bool) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return DirectoryItem{}, false
+	}
+
+	entry := fs.FileInfoToDirEntry(info)
+	if !m.includeEntry(ignore, m.CurrentDirectory, entry) {
+		return DirectoryItem{}, false
+	}
+
+	return withMetadata(newDirectoryItem(m.CurrentDirectory, entry)), true
+}
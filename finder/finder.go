@@ -0,0 +1,380 @@
+// Package finder implements a fuzzy finder bubble which recursively
+// walks a directory in the background and streams ranked matches.
+package finder
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sahilm/fuzzy"
+
+	"github.com/mistakenelf/fm/filesystem"
+	"github.com/mistakenelf/fm/polish"
+)
+
+const (
+	batchSize  = 256
+	maxMatches = 1000
+)
+
+type TitleColor struct {
+	Background lipgloss.AdaptiveColor
+	Foreground lipgloss.AdaptiveColor
+}
+
+// Match represents a single ranked result of the finder.
+type Match struct {
+	Path           string
+	Score          int
+	MatchedIndexes []int
+}
+
+type findResultsMsg struct {
+	generation int
+	matches    []Match
+	done       bool
+}
+
+// Model represents the properties of a finder bubble.
+type Model struct {
+	TextInput           textinput.Model
+	Matches             []Match
+	Cursor              int
+	Root                string
+	HideIgnored         bool
+	Title               string
+	TitleColor          TitleColor
+	Searching           bool
+	width               int
+	height              int
+	query               string
+	generation          int
+	cancel              context.CancelFunc
+	results             chan []Match
+	selectedItemColor   lipgloss.AdaptiveColor
+	unselectedItemColor lipgloss.AdaptiveColor
+}
+
+// New creates a new instance of a finder bubble.
+func New(title string, titleColor TitleColor) Model {
+	input := textinput.New()
+	input.Prompt = "> "
+
+	return Model{
+		TextInput:           input,
+		Title:               title,
+		TitleColor:          titleColor,
+		selectedItemColor:   lipgloss.AdaptiveColor{Light: "212", Dark: "212"},
+		unselectedItemColor: lipgloss.AdaptiveColor{Light: "#000000", Dark: "#ffffff"},
+	}
+}
+
+// rankPaths returns the paths matching the query ordered by score.
+func rankPaths(query string, paths []string) []Match {
+	matches := make([]Match, 0, len(paths))
+
+	if query == "" {
+		for _, path := range paths {
+			matches = append(matches, Match{Path: path})
+		}
+
+		return matches
+	}
+
+	for _, match := range fuzzy.Find(query, paths) {
+		matches = append(matches, Match{
+			Path:           match.Str,
+			Score:          match.Score,
+			MatchedIndexes: match.MatchedIndexes,
+		})
+	}
+
+	return matches
+}
+
+// walk streams batches of ranked matches for the query into results until the
+// walk completes or ctx is cancelled. Ignored files are skipped when ignore is
+// not nil.
+func walk(ctx context.Context, root, query string, ignore *filesystem.IgnoreMatcher, results chan<- []Match) {
+	defer close(results)
+
+	batch := make([]string, 0, batchSize)
+
+	flush := func() {
+		matches := rankPaths(query, batch)
+		batch = make([]string, 0, batchSize)
+
+		if len(matches) == 0 {
+			return
+		}
+
+		select {
+		case results <- matches:
+		case <-ctx.Done():
+		}
+	}
+
+	_ = filesystem.FindFiles(
+		ctx,
+		root,
+		ignore,
+		func(path string, entry fs.DirEntry) bool {
+			return path != root
+		},
+		func(path string, entry fs.DirEntry) {
+			relPath, err := filepath.Rel(root, path)
+			if err != nil {
+				return
+			}
+
+			batch = append(batch, relPath)
+
+			if len(batch) >= batchSize {
+				flush()
+			}
+		},
+	)
+
+	flush()
+}
+
+// waitForResultsCmd waits for the next batch of results of a search.
+func waitForResultsCmd(generation int, results <-chan []Match) tea.Cmd {
+	return func() tea.Msg {
+		matches, ok := <-results
+		if !ok {
+			return findResultsMsg{generation: generation, done: true}
+		}
+
+		return findResultsMsg{generation: generation, matches: matches}
+	}
+}
+
+// searchCmd cancels any running search and starts a new one for the current query.
+func (m *Model) searchCmd() tea.Cmd {
+	m.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m.generation++
+	m.cancel = cancel
+	m.query = m.TextInput.Value()
+	m.Matches = nil
+	m.Cursor = 0
+	m.Searching = true
+	m.results = make(chan []Match)
+
+	var ignore *filesystem.IgnoreMatcher
+	if m.HideIgnored {
+		ignore = filesystem.NewIgnoreMatcher()
+	}
+
+	go walk(ctx, m.Root, m.query, ignore, m.results)
+
+	return waitForResultsCmd(m.generation, m.results)
+}
+
+// StartCmd focuses the finder and starts searching from the given root directory.
+func (m *Model) StartCmd(root string) tea.Cmd {
+	m.Root = root
+	m.TextInput.Reset()
+
+	return tea.Batch(m.TextInput.Focus(), m.searchCmd())
+}
+
+// Stop cancels the running search, if any.
+func (m *Model) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+
+	m.Searching = false
+}
+
+// Close stops the search and blurs the finder.
+func (m *Model) Close() {
+	m.Stop()
+	m.TextInput.Blur()
+}
+
+// GetSelectedPath returns the absolute path of the selected match.
+func (m Model) GetSelectedPath() string {
+	if len(m.Matches) == 0 {
+		return ""
+	}
+
+	return filepath.Join(m.Root, m.Matches[m.Cursor].Path)
+}
+
+// SetSize sets the size of the finder.
+func (m *Model) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+	m.TextInput.Width = width - len(m.TextInput.Prompt) - 1
+}
+
+// SetTheme sets the colors of the matches.
+func (m *Model) SetTheme(selectedItemColor, unselectedItemColor lipgloss.AdaptiveColor) {
+	m.selectedItemColor = selectedItemColor
+	m.unselectedItemColor = unselectedItemColor
+}
+
+// mergeMatches adds the matches to the current results keeping them ranked.
+func (m *Model) mergeMatches(matches []Match) {
+	m.Matches = append(m.Matches, matches...)
+
+	sort.SliceStable(m.Matches, func(i, j int) bool {
+		if m.Matches[i].Score != m.Matches[j].Score {
+			return m.Matches[i].Score > m.Matches[j].Score
+		}
+
+		return len(m.Matches[i].Path) < len(m.Matches[j].Path)
+	})
+
+	if len(m.Matches) > maxMatches {
+		m.Matches = m.Matches[:maxMatches]
+	}
+}
+
+// Update handles UI interactions with the finder bubble.
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case findResultsMsg:
+		if msg.generation != m.generation {
+			return m, nil
+		}
+
+		if msg.done {
+			m.Searching = false
+
+			return m, nil
+		}
+
+		m.mergeMatches(msg.matches)
+
+		return m, waitForResultsCmd(m.generation, m.results)
+	case tea.KeyMsg:
+		if !m.TextInput.Focused() {
+			return m, nil
+		}
+
+		switch msg.Type {
+		case tea.KeyUp, tea.KeyCtrlP:
+			if m.Cursor > 0 {
+				m.Cursor--
+			}
+
+			return m, nil
+		case tea.KeyDown, tea.KeyCtrlN:
+			if m.Cursor < len(m.Matches)-1 {
+				m.Cursor++
+			}
+
+			return m, nil
+		}
+
+		m.TextInput, cmd = m.TextInput.Update(msg)
+
+		if m.TextInput.Value() != m.query {
+			return m, tea.Batch(cmd, m.searchCmd())
+		}
+
+		return m, cmd
+	}
+
+	m.TextInput, cmd = m.TextInput.Update(msg)
+
+	return m, cmd
+}
+
+// renderMatch renders a match highlighting the matched characters.
+func (m Model) renderMatch(match Match, selected bool) string {
+	textColor := m.unselectedItemColor
+
+	if selected {
+		textColor = m.selectedItemColor
+	}
+
+	style := lipgloss.NewStyle().Foreground(textColor).Bold(selected)
+	matchedStyle := style.Foreground(polish.Colors.Yellow500).Bold(true)
+
+	matched := make(map[int]struct{}, len(match.MatchedIndexes))
+	for _, index := range match.MatchedIndexes {
+		matched[index] = struct{}{}
+	}
+
+	var row, run strings.Builder
+	runMatched := false
+
+	// Render contiguous runs of matched and unmatched characters together
+	// rather than styling each character on its own.
+	for index, char := range match.Path {
+		_, isMatched := matched[index]
+
+		if isMatched != runMatched && run.Len() > 0 {
+			if runMatched {
+				row.WriteString(matchedStyle.Render(run.String()))
+			} else {
+				row.WriteString(style.Render(run.String()))
+			}
+
+			run.Reset()
+		}
+
+		runMatched = isMatched
+		run.WriteRune(char)
+	}
+
+	if runMatched {
+		row.WriteString(matchedStyle.Render(run.String()))
+	} else {
+		row.WriteString(style.Render(run.String()))
+	}
+
+	return row.String()
+}
+
+// View returns a string representation of the finder bubble.
+func (m Model) View() string {
+	var matchList strings.Builder
+
+	titleText := lipgloss.NewStyle().Bold(true).
+		Background(m.TitleColor.Background).
+		Foreground(m.TitleColor.Foreground).
+		Padding(0, 1).
+		Italic(true).
+		Render(m.Title)
+
+	status := fmt.Sprintf("%d matches", len(m.Matches))
+	if m.Searching {
+		status += " (searching...)"
+	}
+
+	visibleRows := max(m.height-3, 0)
+	start := max(m.Cursor-visibleRows+1, 0)
+
+	for i := start; i < len(m.Matches) && i < start+visibleRows; i++ {
+		matchList.WriteString(m.renderMatch(m.Matches[i], i == m.Cursor) + "\n")
+	}
+
+	return lipgloss.NewStyle().
+		Width(m.width).
+		Height(m.height).
+		Render(lipgloss.JoinVertical(
+			lipgloss.Top,
+			titleText,
+			m.TextInput.View(),
+			lipgloss.NewStyle().Foreground(polish.Colors.Yellow500).Render(status),
+			matchList.String(),
+		))
+}
@@ -0,0 +1,150 @@
+// Package bookmarks persists named bookmarks to directories in the data
+// directory of fm.
+package bookmarks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mistakenelf/fm/filesystem"
+)
+
+const (
+	fileName = "bookmarks.json"
+
+	// Prefix marks a command line argument as the name of a bookmark.
+	Prefix = "@"
+)
+
+// Bookmark is a named directory.
+type Bookmark struct {
+	Name string
+	Path string
+}
+
+// Store holds the bookmarks read from a bookmarks file.
+type Store struct {
+	path      string
+	bookmarks map[string]string
+}
+
+// DefaultPath returns the path of the bookmarks file in the data directory.
+func DefaultPath() (string, error) {
+	dataDirectory, err := filesystem.GetDataDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dataDirectory, fileName), nil
+}
+
+// Load reads the bookmarks from the default bookmarks file.
+func Load() (Store, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return Store{}, err
+	}
+
+	return LoadFile(path)
+}
+
+// LoadFile reads the bookmarks from the file provided. A file which does not
+// exist yet holds no bookmarks.
+func LoadFile(path string) (Store, error) {
+	store := Store{
+		path:      path,
+		bookmarks: make(map[string]string),
+	}
+
+	content, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+
+	if err != nil {
+		return store, err
+	}
+
+	if err := json.Unmarshal(content, &store.bookmarks); err != nil {
+		return store, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	return store, nil
+}
+
+// Save writes the bookmarks back to the file they were loaded from.
+func (s Store) Save() error {
+	content, err := json.MarshalIndent(s.bookmarks, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return filesystem.WriteFileAtomically(s.path, append(content, '\n'))
+}
+
+// Get returns the path of the bookmark with the given name.
+func (s Store) Get(name string) (string, bool) {
+	path, ok := s.bookmarks[strings.TrimPrefix(name, Prefix)]
+
+	return path, ok
+}
+
+// Set bookmarks the path under the given name, replacing any bookmark
+// already using it.
+func (s Store) Set(name, path string) error {
+	name = strings.TrimSpace(strings.TrimPrefix(name, Prefix))
+
+	if name == "" {
+		return errors.New("bookmark name cannot be empty")
+	}
+
+	s.bookmarks[name] = path
+
+	return nil
+}
+
+// Delete removes the bookmark with the given name.
+func (s Store) Delete(name string) {
+	delete(s.bookmarks, strings.TrimPrefix(name, Prefix))
+}
+
+// List returns all bookmarks ordered by name.
+func (s Store) List() []Bookmark {
+	list := make([]Bookmark, 0, len(s.bookmarks))
+
+	for name, path := range s.bookmarks {
+		list = append(list, Bookmark{Name: name, Path: path})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
+
+// Resolve returns the path of the bookmark referenced by an argument of the
+// form @name. Any other argument is returned unchanged.
+func Resolve(arg string) (string, error) {
+	if !strings.HasPrefix(arg, Prefix) {
+		return arg, nil
+	}
+
+	store, err := Load()
+	if err != nil {
+		return "", err
+	}
+
+	path, ok := store.Get(arg)
+	if !ok {
+		return "", fmt.Errorf("no bookmark named %s", strings.TrimPrefix(arg, Prefix))
+	}
+
+	return path, nil
+}
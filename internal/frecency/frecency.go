@@ -0,0 +1,245 @@
+// Package frecency keeps a database of visited directories ranked by how
+// frequently and how recently they were visited.
+package frecency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mistakenelf/fm/filesystem"
+)
+
+const (
+	fileName = "frecency.json"
+
+	// maxTotalRank is the total rank after which all ranks are aged so that
+	// directories which are no longer visited eventually drop out.
+	maxTotalRank = 10000
+	agingFactor  = 0.9
+	minRank      = 1
+)
+
+// mu serializes updates to the database file made by Record.
+var mu sync.Mutex
+
+// Entry is a visited directory.
+type Entry struct {
+	Path       string    `json:"-"`
+	Rank       float64   `json:"rank"`
+	LastAccess time.Time `json:"last_access"`
+}
+
+// Database holds the entries read from a frecency database file.
+type Database struct {
+	path    string
+	entries map[string]*Entry
+}
+
+// DefaultPath returns the path of the database in the data directory.
+func DefaultPath() (string, error) {
+	dataDirectory, err := filesystem.GetDataDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dataDirectory, fileName), nil
+}
+
+// Load reads the default frecency database.
+func Load() (Database, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return Database{}, err
+	}
+
+	return LoadFile(path)
+}
+
+// LoadFile reads the frecency database from the file provided. A file which
+// does not exist yet holds no entries.
+func LoadFile(path string) (Database, error) {
+	database := Database{
+		path:    path,
+		entries: make(map[string]*Entry),
+	}
+
+	content, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return database, nil
+	}
+
+	if err != nil {
+		return database, err
+	}
+
+	if err := json.Unmarshal(content, &database.entries); err != nil {
+		return database, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	for path, entry := range database.entries {
+		entry.Path = path
+	}
+
+	return database, nil
+}
+
+// Save writes the database back to the file it was loaded from.
+func (d Database) Save() error {
+	content, err := json.Marshal(d.entries)
+	if err != nil {
+		return err
+	}
+
+	return filesystem.WriteFileAtomically(d.path, content)
+}
+
+// Add records a visit to the directory at the given time.
+func (d Database) Add(path string, now time.Time) {
+	entry, ok := d.entries[path]
+	if !ok {
+		entry = &Entry{Path: path}
+		d.entries[path] = entry
+	}
+
+	entry.Rank++
+	entry.LastAccess = now
+
+	d.age()
+}
+
+// age scales down every rank once the total grows too large, forgetting the
+// directories whose rank falls below the minimum.
+func (d Database) age() {
+	total := 0.0
+
+	for _, entry := range d.entries {
+		total += entry.Rank
+	}
+
+	if total <= maxTotalRank {
+		return
+	}
+
+	for path, entry := range d.entries {
+		entry.Rank *= agingFactor
+
+		if entry.Rank < minRank {
+			delete(d.entries, path)
+		}
+	}
+}
+
+// Score weights the rank of an entry by how recently it was visited.
+func Score(entry Entry, now time.Time) float64 {
+	elapsed := now.Sub(entry.LastAccess)
+
+	switch {
+	case elapsed < time.Hour:
+		return entry.Rank * 4
+	case elapsed < 24*time.Hour:
+		return entry.Rank * 2
+	case elapsed < 7*24*time.Hour:
+		return entry.Rank / 2
+	default:
+		return entry.Rank / 4
+	}
+}
+
+// matches reports whether the path contains each of the query fragments in
+// order, with the last fragment within the final path component. Matching is
+// case insensitive unless the query contains an upper case letter.
+func matches(path string, fragments []string) bool {
+	if len(fragments) == 0 {
+		return true
+	}
+
+	if strings.ToLower(strings.Join(fragments, "")) == strings.Join(fragments, "") {
+		path = strings.ToLower(path)
+	}
+
+	if !strings.Contains(filepath.Base(path), fragments[len(fragments)-1]) {
+		return false
+	}
+
+	for _, fragment := range fragments {
+		index := strings.Index(path, fragment)
+		if index == -1 {
+			return false
+		}
+
+		path = path[index+len(fragment):]
+	}
+
+	return true
+}
+
+// Query returns the entries matching all of the query fragments, best match
+// first. Directories which no longer exist and the excluded directory are
+// left out.
+func (d Database) Query(fragments []string, exclude string, now time.Time) []Entry {
+	var results []Entry
+
+	for path, entry := range d.entries {
+		if path == exclude || !matches(path, fragments) {
+			continue
+		}
+
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			continue
+		}
+
+		results = append(results, *entry)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		scoreI, scoreJ := Score(results[i], now), Score(results[j], now)
+
+		if scoreI != scoreJ {
+			return scoreI > scoreJ
+		}
+
+		return results[i].Path < results[j].Path
+	})
+
+	return results
+}
+
+// Record loads the default database, records a visit to the directory and
+// saves it again.
+func Record(path string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	database, err := Load()
+	if err != nil {
+		return err
+	}
+
+	database.Add(path, time.Now())
+
+	return database.Save()
+}
+
+// Find returns the best match for the query in the default database other
+// than the excluded directory.
+func Find(query, exclude string) (string, error) {
+	database, err := Load()
+	if err != nil {
+		return "", err
+	}
+
+	results := database.Query(strings.Fields(query), exclude, time.Now())
+	if len(results) == 0 {
+		return "", fmt.Errorf("no directory matches %q", query)
+	}
+
+	return results[0].Path, nil
+}
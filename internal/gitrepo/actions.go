@@ -0,0 +1,437 @@
+package gitrepo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ErrNotInWorktree is returned when a path is not within a git work tree.
+var ErrNotInWorktree = errors.New("not within a git work tree")
+
+// target is a path within a work tree.
+type target struct {
+	repo     *git.Repository
+	worktree *git.Worktree
+	// name is the path relative to the root of the work tree using forward
+	// slashes, empty for the root itself.
+	name string
+}
+
+// openTarget opens the work tree containing path.
+func openTarget(path string) (target, error) {
+	repo, err := Open(path)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		return target{}, ErrNotInWorktree
+	}
+
+	if err != nil {
+		return target{}, err
+	}
+
+	worktree, err := repo.Worktree()
+	if errors.Is(err, git.ErrIsBareRepository) {
+		return target{}, ErrNotInWorktree
+	}
+
+	if err != nil {
+		return target{}, err
+	}
+
+	name, err := filepath.Rel(worktree.Filesystem.Root(), path)
+	if err != nil {
+		return target{}, err
+	}
+
+	if name == "." {
+		name = ""
+	}
+
+	return target{repo: repo, worktree: worktree, name: filepath.ToSlash(name)}, nil
+}
+
+// contains reports whether the file with the given name is the target or
+// within it.
+func (t target) contains(name string) bool {
+	return t.name == "" || name == t.name || strings.HasPrefix(name, t.name+"/")
+}
+
+// changedFiles returns the status of the changed files within the target.
+func (t target) changedFiles() (git.Status, error) {
+	status, err := t.worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(git.Status)
+
+	for name, fileStatus := range status {
+		if t.contains(name) {
+			changed[name] = fileStatus
+		}
+	}
+
+	return changed, nil
+}
+
+// headTree returns the tree of the head commit, or nil when there are no
+// commits yet.
+func (t target) headTree() (*object.Tree, error) {
+	head, err := t.repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := t.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	return commit.Tree()
+}
+
+// Stage adds the changes to the file or directory at path to the index.
+func Stage(path string) error {
+	t, err := openTarget(path)
+	if err != nil {
+		return err
+	}
+
+	name := t.name
+	if name == "" {
+		name = "."
+	}
+
+	return t.worktree.AddWithOptions(&git.AddOptions{Path: name})
+}
+
+// Unstage resets the index entries of the file or directory at path to the
+// head commit, keeping the changes in the work tree.
+func Unstage(path string) error {
+	t, err := openTarget(path)
+	if err != nil {
+		return err
+	}
+
+	changed, err := t.changedFiles()
+	if err != nil {
+		return err
+	}
+
+	tree, err := t.headTree()
+	if err != nil {
+		return err
+	}
+
+	idx, err := t.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	for name, fileStatus := range changed {
+		if fileStatus.Staging == git.Unmodified || fileStatus.Staging == git.Untracked {
+			continue
+		}
+
+		var file *object.File
+
+		if tree != nil {
+			file, err = tree.File(name)
+			if err != nil && !errors.Is(err, object.ErrFileNotFound) {
+				return err
+			}
+		}
+
+		// Files added since the head commit are removed from the index.
+		if file == nil {
+			if _, err := idx.Remove(name); err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+				return err
+			}
+
+			continue
+		}
+
+		entry, err := idx.Entry(name)
+		if errors.Is(err, index.ErrEntryNotFound) {
+			entry = idx.Add(name)
+		} else if err != nil {
+			return err
+		}
+
+		// Clearing the cached file stats makes git compare the content of
+		// the work tree with the entry again.
+		*entry = index.Entry{Name: name, Hash: file.Hash, Mode: file.Mode}
+	}
+
+	return t.repo.Storer.SetIndex(idx)
+}
+
+// Discard replaces the changes to the file or directory at path in the work
+// tree with the content of the index. Untracked files are left alone.
+func Discard(path string) error {
+	t, err := openTarget(path)
+	if err != nil {
+		return err
+	}
+
+	changed, err := t.changedFiles()
+	if err != nil {
+		return err
+	}
+
+	idx, err := t.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	root := t.worktree.Filesystem.Root()
+
+	for name, fileStatus := range changed {
+		if fileStatus.Worktree == git.Unmodified || fileStatus.Worktree == git.Untracked {
+			continue
+		}
+
+		entry, err := idx.Entry(name)
+		if err != nil {
+			return err
+		}
+
+		blob, err := t.repo.BlobObject(entry.Hash)
+		if err != nil {
+			return err
+		}
+
+		content, err := readBlob(blob)
+		if err != nil {
+			return err
+		}
+
+		if err := writeWorktreeFile(filepath.Join(root, filepath.FromSlash(name)), content, entry.Mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readBlob returns the content of a blob.
+func readBlob(blob *object.Blob) (string, error) {
+	reader, err := blob.Reader()
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// writeWorktreeFile writes a file of the work tree with the mode it has in
+// the index.
+func writeWorktreeFile(path, content string, mode filemode.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if mode == filemode.Symlink {
+		return os.Symlink(content, path)
+	}
+
+	permissions := fs.FileMode(0o644)
+	if mode == filemode.Executable {
+		permissions = 0o755
+	}
+
+	return os.WriteFile(path, []byte(content), permissions)
+}
+
+// Diff returns the changes to the file or directory at path since the head
+// commit as a unified diff, including both staged and unstaged changes.
+func Diff(path string) (string, error) {
+	t, err := openTarget(path)
+	if err != nil {
+		return "", err
+	}
+
+	changed, err := t.changedFiles()
+	if err != nil {
+		return "", err
+	}
+
+	tree, err := t.headTree()
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var filePatches patch
+
+	for _, name := range names {
+		from, err := headVersion(tree, name)
+		if err != nil {
+			return "", err
+		}
+
+		to, err := worktreeVersion(t.worktree.Filesystem.Root(), name)
+		if err != nil {
+			return "", err
+		}
+
+		if from == nil && to == nil {
+			continue
+		}
+
+		filePatches = append(filePatches, filePatch{from: from, to: to})
+	}
+
+	var diff strings.Builder
+
+	if err := fdiff.NewUnifiedEncoder(&diff, diffContextLines).Encode(filePatches); err != nil {
+		return "", err
+	}
+
+	return diff.String(), nil
+}
+
+// headVersion returns the version of a file in the head commit, or nil when
+// it is not in the head commit.
+func headVersion(tree *object.Tree, name string) (*fileVersion, error) {
+	if tree == nil {
+		return nil, nil
+	}
+
+	file, err := tree.File(name)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileVersion{hash: file.Hash, mode: file.Mode, path: name, content: content}, nil
+}
+
+// worktreeVersion returns the version of a file in the work tree, or nil when
+// it has been deleted.
+func worktreeVersion(root, name string) (*fileVersion, error) {
+	path := filepath.Join(root, filepath.FromSlash(name))
+
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var content string
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		content, err = os.Readlink(path)
+	} else {
+		var data []byte
+		data, err = os.ReadFile(filepath.Clean(path))
+		content = string(data)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := filemode.NewFromOSFileMode(info.Mode())
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileVersion{
+		hash:    plumbing.ComputeHash(plumbing.BlobObject, []byte(content)),
+		mode:    mode,
+		path:    name,
+		content: content,
+	}, nil
+}
+
+// Blame returns each line of the file at path as of the head commit along
+// with the commit, author and date which last changed it.
+func Blame(path string) (string, error) {
+	t, err := openTarget(path)
+	if err != nil {
+		return "", err
+	}
+
+	head, err := t.repo.Head()
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := t.repo.CommitObject(head.Hash())
+	if err != nil {
+		return "", err
+	}
+
+	result, err := git.Blame(commit, t.name)
+	if err != nil {
+		return "", err
+	}
+
+	authorWidth := 0
+	for _, line := range result.Lines {
+		authorWidth = max(authorWidth, len(line.AuthorName))
+	}
+
+	var blame strings.Builder
+
+	for _, line := range result.Lines {
+		fmt.Fprintf(
+			&blame,
+			"%s %-*s %s │ %s\n",
+			line.Hash.String()[:7],
+			authorWidth,
+			line.AuthorName,
+			line.Date.Format("2006-01-02"),
+			line.Text,
+		)
+	}
+
+	return blame.String(), nil
+}
@@ -0,0 +1,451 @@
+package gitrepo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// newRepository creates a repository in a temporary directory with a single
+// commit holding the files provided, returning the root of its work tree.
+func newRepository(t *testing.T, files map[string]string) (string, *git.Repository) {
+	t.Helper()
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		writeFile(t, root, name, content)
+	}
+
+	commit(t, repo)
+
+	return root, repo
+}
+
+// commit commits every change of the work tree.
+func commit(t *testing.T, repo *git.Repository) {
+	t.Helper()
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := worktree.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = worktree.Commit("commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "Tester", Email: "tester@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeFile writes a file of the work tree, creating its directories.
+func writeFile(t *testing.T, root, name, content string) {
+	t.Helper()
+
+	path := filepath.Join(root, filepath.FromSlash(name))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readFile returns the content of a file of the work tree.
+func readFile(t *testing.T, root, name string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+// fileStatus returns the git status of a file of the work tree.
+func fileStatus(t *testing.T, repo *git.Repository, name string) git.FileStatus {
+	t.Helper()
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := worktree.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return *status.File(name)
+}
+
+func TestStage(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(t *testing.T, root string)
+		path    string
+		file    string
+		staging git.StatusCode
+	}{
+		{
+			name:    "modified file",
+			change:  func(t *testing.T, root string) { writeFile(t, root, "a.txt", "changed\n") },
+			path:    "a.txt",
+			file:    "a.txt",
+			staging: git.Modified,
+		},
+		{
+			name:    "new file",
+			change:  func(t *testing.T, root string) { writeFile(t, root, "new.txt", "new\n") },
+			path:    "new.txt",
+			file:    "new.txt",
+			staging: git.Added,
+		},
+		{
+			name: "deleted file",
+			change: func(t *testing.T, root string) {
+				if err := os.Remove(filepath.Join(root, "a.txt")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			path:    "a.txt",
+			file:    "a.txt",
+			staging: git.Deleted,
+		},
+		{
+			name:    "file within directory",
+			change:  func(t *testing.T, root string) { writeFile(t, root, "dir/b.txt", "changed\n") },
+			path:    "dir",
+			file:    "dir/b.txt",
+			staging: git.Modified,
+		},
+		{
+			name: "symlink",
+			change: func(t *testing.T, root string) {
+				if err := os.Symlink("dir/b.txt", filepath.Join(root, "link")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			path:    "link",
+			file:    "link",
+			staging: git.Added,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, repo := newRepository(t, map[string]string{"a.txt": "a\n", "dir/b.txt": "b\n"})
+			test.change(t, root)
+
+			if err := Stage(filepath.Join(root, test.path)); err != nil {
+				t.Fatal(err)
+			}
+
+			status := fileStatus(t, repo, test.file)
+			if status.Staging != test.staging || status.Worktree != git.Unmodified {
+				t.Errorf("status of %s = %c%c, want %c ", test.file, status.Staging, status.Worktree, test.staging)
+			}
+		})
+	}
+}
+
+func TestStageLeavesOtherFiles(t *testing.T) {
+	root, repo := newRepository(t, map[string]string{"a.txt": "a\n", "dir/b.txt": "b\n"})
+	writeFile(t, root, "a.txt", "changed\n")
+	writeFile(t, root, "dir/b.txt", "changed\n")
+
+	if err := Stage(filepath.Join(root, "dir")); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := fileStatus(t, repo, "a.txt"); status.Staging != git.Unmodified || status.Worktree != git.Modified {
+		t.Errorf("status of a.txt = %c%c, want  M", status.Staging, status.Worktree)
+	}
+}
+
+func TestUnstage(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(t *testing.T, root string)
+		file     string
+		staging  git.StatusCode
+		worktree git.StatusCode
+	}{
+		{
+			name:     "modified file",
+			change:   func(t *testing.T, root string) { writeFile(t, root, "a.txt", "changed\n") },
+			file:     "a.txt",
+			staging:  git.Unmodified,
+			worktree: git.Modified,
+		},
+		{
+			name:     "new file",
+			change:   func(t *testing.T, root string) { writeFile(t, root, "new.txt", "new\n") },
+			file:     "new.txt",
+			staging:  git.Untracked,
+			worktree: git.Untracked,
+		},
+		{
+			name: "deleted file",
+			change: func(t *testing.T, root string) {
+				if err := os.Remove(filepath.Join(root, "a.txt")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			file:     "a.txt",
+			staging:  git.Unmodified,
+			worktree: git.Deleted,
+		},
+		{
+			name: "symlink",
+			change: func(t *testing.T, root string) {
+				if err := os.Symlink("a.txt", filepath.Join(root, "link")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			file:     "link",
+			staging:  git.Untracked,
+			worktree: git.Untracked,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, repo := newRepository(t, map[string]string{"a.txt": "a\n"})
+			test.change(t, root)
+
+			path := filepath.Join(root, test.file)
+
+			if err := Stage(path); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := Unstage(path); err != nil {
+				t.Fatal(err)
+			}
+
+			status := fileStatus(t, repo, test.file)
+			if status.Staging != test.staging || status.Worktree != test.worktree {
+				t.Errorf("status of %s = %c%c, want %c%c", test.file, status.Staging, status.Worktree, test.staging, test.worktree)
+			}
+		})
+	}
+}
+
+func TestUnstageKeepsWorktree(t *testing.T) {
+	root, _ := newRepository(t, map[string]string{"a.txt": "a\n"})
+	writeFile(t, root, "a.txt", "changed\n")
+
+	if err := Stage(filepath.Join(root, "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Unstage(filepath.Join(root, "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	if content := readFile(t, root, "a.txt"); content != "changed\n" {
+		t.Errorf("content of a.txt = %q, want %q", content, "changed\n")
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, root string)
+		file   string
+		want   string
+	}{
+		{
+			name:   "modified file",
+			change: func(t *testing.T, root string) { writeFile(t, root, "a.txt", "changed\n") },
+			file:   "a.txt",
+			want:   "a\n",
+		},
+		{
+			name: "deleted file",
+			change: func(t *testing.T, root string) {
+				if err := os.Remove(filepath.Join(root, "dir/b.txt")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			file: "dir/b.txt",
+			want: "b\n",
+		},
+		{
+			name: "staged and then modified file",
+			change: func(t *testing.T, root string) {
+				writeFile(t, root, "a.txt", "staged\n")
+
+				if err := Stage(filepath.Join(root, "a.txt")); err != nil {
+					t.Fatal(err)
+				}
+
+				writeFile(t, root, "a.txt", "unstaged\n")
+			},
+			file: "a.txt",
+			want: "staged\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, _ := newRepository(t, map[string]string{"a.txt": "a\n", "dir/b.txt": "b\n"})
+			test.change(t, root)
+
+			if err := Discard(filepath.Join(root, test.file)); err != nil {
+				t.Fatal(err)
+			}
+
+			if content := readFile(t, root, test.file); content != test.want {
+				t.Errorf("content of %s = %q, want %q", test.file, content, test.want)
+			}
+		})
+	}
+}
+
+func TestDiscardSymlink(t *testing.T) {
+	root, repo := newRepository(t, map[string]string{"a.txt": "a\n", "b.txt": "b\n"})
+
+	link := filepath.Join(root, "link")
+	if err := os.Symlink("a.txt", link); err != nil {
+		t.Fatal(err)
+	}
+
+	commit(t, repo)
+
+	if err := os.Remove(link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink("b.txt", link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Discard(link); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := os.Readlink(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if target != "a.txt" {
+		t.Errorf("link points to %q, want %q", target, "a.txt")
+	}
+}
+
+func TestDiscardLeavesUntrackedFiles(t *testing.T) {
+	root, _ := newRepository(t, map[string]string{"a.txt": "a\n"})
+	writeFile(t, root, "a.txt", "changed\n")
+	writeFile(t, root, "new.txt", "new\n")
+
+	if err := Discard(root); err != nil {
+		t.Fatal(err)
+	}
+
+	if content := readFile(t, root, "a.txt"); content != "a\n" {
+		t.Errorf("content of a.txt = %q, want %q", content, "a\n")
+	}
+
+	if content := readFile(t, root, "new.txt"); content != "new\n" {
+		t.Errorf("content of new.txt = %q, want %q", content, "new\n")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	root, repo := newRepository(t, map[string]string{"a.txt": "a\n", "b.txt": "b\n"})
+	writeFile(t, root, "a.txt", "staged\n")
+
+	if err := Stage(filepath.Join(root, "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, root, "new.txt", "new\n")
+
+	if err := os.Remove(filepath.Join(root, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := Diff(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"-a\n", "+staged\n", "-b\n", "+new\n"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff doesn't contain %q:\n%s", want, diff)
+		}
+	}
+
+	diff, err = Diff(filepath.Join(root, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(diff, "b.txt") {
+		t.Errorf("diff of a.txt contains b.txt:\n%s", diff)
+	}
+
+	commit(t, repo)
+
+	diff, err = Diff(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff != "" {
+		t.Errorf("diff of a clean work tree = %q, want none", diff)
+	}
+}
+
+func TestBlame(t *testing.T) {
+	root, _ := newRepository(t, map[string]string{"a.txt": "first\nsecond\n"})
+
+	blame, err := Blame(filepath.Join(root, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(blame, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("blame has %d lines, want 2:\n%s", len(lines), blame)
+	}
+
+	for i, text := range []string{"first", "second"} {
+		if !strings.Contains(lines[i], "Tester") || !strings.HasSuffix(lines[i], "│ "+text) {
+			t.Errorf("blame line %d = %q, want the author and %q", i+1, lines[i], text)
+		}
+	}
+}
+
+func TestNotInWorktree(t *testing.T) {
+	path := t.TempDir()
+
+	if err := Stage(path); !errors.Is(err, ErrNotInWorktree) {
+		t.Errorf("Stage(%s) = %v, want %v", path, err, ErrNotInWorktree)
+	}
+}
@@ -0,0 +1,108 @@
+package gitrepo
+
+import (
+	"bytes"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// diffContextLines is how many unchanged lines are shown around changes.
+const diffContextLines = 3
+
+// fileVersion is the content of a file at one side of a diff.
+type fileVersion struct {
+	hash    plumbing.Hash
+	mode    filemode.FileMode
+	path    string
+	content string
+}
+
+func (f *fileVersion) Hash() plumbing.Hash     { return f.hash }
+func (f *fileVersion) Mode() filemode.FileMode { return f.mode }
+func (f *fileVersion) Path() string            { return f.path }
+
+// chunk is a run of lines which are unchanged, added or deleted.
+type chunk struct {
+	content   string
+	operation fdiff.Operation
+}
+
+func (c chunk) Content() string       { return c.content }
+func (c chunk) Type() fdiff.Operation { return c.operation }
+
+// filePatch holds the changes between two versions of a file, either of which
+// is nil when the file was added or deleted.
+type filePatch struct {
+	from *fileVersion
+	to   *fileVersion
+}
+
+// IsBinary reports whether either version of the file contains a NUL byte,
+// the same heuristic git uses.
+func (p filePatch) IsBinary() bool {
+	for _, version := range []*fileVersion{p.from, p.to} {
+		if version != nil && bytes.IndexByte([]byte(version.content), 0) != -1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p filePatch) Files() (fdiff.File, fdiff.File) {
+	var from, to fdiff.File
+
+	// Only assign non-nil versions so that a missing side is a nil interface.
+	if p.from != nil {
+		from = p.from
+	}
+
+	if p.to != nil {
+		to = p.to
+	}
+
+	return from, to
+}
+
+func (p filePatch) Chunks() []fdiff.Chunk {
+	var fromContent, toContent string
+
+	if p.IsBinary() {
+		return nil
+	}
+
+	if p.from != nil {
+		fromContent = p.from.content
+	}
+
+	if p.to != nil {
+		toContent = p.to.content
+	}
+
+	var chunks []fdiff.Chunk
+
+	for _, d := range diff.Do(fromContent, toContent) {
+		operation := fdiff.Equal
+
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			operation = fdiff.Add
+		case diffmatchpatch.DiffDelete:
+			operation = fdiff.Delete
+		}
+
+		chunks = append(chunks, chunk{content: d.Text, operation: operation})
+	}
+
+	return chunks
+}
+
+// patch is a set of file patches which can be written as a unified diff.
+type patch []fdiff.FilePatch
+
+func (p patch) FilePatches() []fdiff.FilePatch { return p }
+func (p patch) Message() string                { return "" }
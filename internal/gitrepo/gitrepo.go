@@ -0,0 +1,402 @@
+// Package gitrepo reads the status of git work trees in pure Go so that it
+// works without a git binary.
+package gitrepo
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// FileStatus is the git status of a file. Statuses are ordered by importance
+// so that a directory shows the most important status of its contents.
+type FileStatus int
+
+const (
+	Unmodified FileStatus = iota
+	Ignored
+	Untracked
+	Staged
+	Modified
+	Conflicted
+)
+
+// String returns the name of the status.
+func (s FileStatus) String() string {
+	switch s {
+	case Ignored:
+		return "ignored"
+	case Untracked:
+		return "untracked"
+	case Staged:
+		return "staged"
+	case Modified:
+		return "modified"
+	case Conflicted:
+		return "conflicted"
+	default:
+		return "unmodified"
+	}
+}
+
+// Symbol returns the single character shown next to an item with the status.
+func (s FileStatus) Symbol() string {
+	switch s {
+	case Ignored:
+		return "!"
+	case Untracked:
+		return "?"
+	case Staged:
+		return "S"
+	case Modified:
+		return "M"
+	case Conflicted:
+		return "U"
+	default:
+		return " "
+	}
+}
+
+// Branch is the checked out branch along with how far it is ahead of and
+// behind its upstream branch.
+type Branch struct {
+	Name        string
+	Ahead       int
+	Behind      int
+	HasUpstream bool
+}
+
+// String returns the name of the branch followed by the ahead and behind
+// counts, when they are not zero.
+func (b Branch) String() string {
+	description := b.Name
+
+	if b.Ahead > 0 {
+		description += fmt.Sprintf(" ↑%d", b.Ahead)
+	}
+
+	if b.Behind > 0 {
+		description += fmt.Sprintf(" ↓%d", b.Behind)
+	}
+
+	return description
+}
+
+// Status is a snapshot of the status of a work tree.
+type Status struct {
+	Root    string
+	Branch  Branch
+	files   map[string]FileStatus
+	ignored gitignore.Matcher
+}
+
+// Open opens the repository of the work tree containing path.
+func Open(path string) (*git.Repository, error) {
+	return git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+}
+
+// ReadStatus reads the status of the work tree containing path. A nil status
+// is returned when path is not within a work tree.
+func ReadStatus(path string) (*Status, error) {
+	repo, err := Open(path)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	worktree, err := repo.Worktree()
+	if errors.Is(err, git.ErrIsBareRepository) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	worktreeStatus, err := worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	patterns, err := gitignore.ReadPatterns(worktree.Filesystem, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		Root:    worktree.Filesystem.Root(),
+		files:   make(map[string]FileStatus),
+		ignored: gitignore.NewMatcher(patterns),
+	}
+
+	for name, fileStatus := range worktreeStatus {
+		status.add(filepath.Join(status.Root, filepath.FromSlash(name)), toFileStatus(fileStatus))
+	}
+
+	status.Branch, err = readBranch(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// toFileStatus converts the status codes of go-git into a single status,
+// preferring changes in the work tree over those which are staged.
+func toFileStatus(fileStatus *git.FileStatus) FileStatus {
+	switch {
+	case fileStatus.Staging == git.UpdatedButUnmerged || fileStatus.Worktree == git.UpdatedButUnmerged:
+		return Conflicted
+	case fileStatus.Worktree == git.Untracked:
+		return Untracked
+	case fileStatus.Worktree != git.Unmodified:
+		return Modified
+	case fileStatus.Staging != git.Unmodified:
+		return Staged
+	default:
+		return Unmodified
+	}
+}
+
+// add records the status of a file, raising the status of the directories
+// containing it.
+func (s *Status) add(path string, fileStatus FileStatus) {
+	for {
+		if fileStatus > s.files[path] {
+			s.files[path] = fileStatus
+		}
+
+		if path == s.Root || !strings.HasPrefix(path, s.Root) {
+			return
+		}
+
+		path = filepath.Dir(path)
+	}
+}
+
+// Get returns the status of the file or directory at path.
+func (s *Status) Get(path string, isDirectory bool) FileStatus {
+	if fileStatus, ok := s.files[path]; ok {
+		return fileStatus
+	}
+
+	relativePath, err := filepath.Rel(s.Root, path)
+	if err != nil || relativePath == "." || strings.HasPrefix(relativePath, "..") {
+		return Unmodified
+	}
+
+	if s.ignored.Match(strings.Split(relativePath, string(filepath.Separator)), isDirectory) {
+		return Ignored
+	}
+
+	return Unmodified
+}
+
+// readBranch returns the checked out branch, or the abbreviated commit when
+// the head is detached.
+func readBranch(repo *git.Repository) (Branch, error) {
+	head, err := repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		// A new repository has no commits yet.
+		ref, err := repo.Storer.Reference(plumbing.HEAD)
+		if err != nil {
+			return Branch{}, err
+		}
+
+		return Branch{Name: ref.Target().Short()}, nil
+	}
+
+	if err != nil {
+		return Branch{}, err
+	}
+
+	if !head.Name().IsBranch() {
+		return Branch{Name: head.Hash().String()[:7]}, nil
+	}
+
+	branch := Branch{Name: head.Name().Short()}
+
+	config, err := repo.Config()
+	if err != nil {
+		return Branch{}, err
+	}
+
+	branchConfig, ok := config.Branches[branch.Name]
+	if !ok || branchConfig.Remote == "" || branchConfig.Merge == "" {
+		return branch, nil
+	}
+
+	upstreamName := plumbing.NewRemoteReferenceName(branchConfig.Remote, branchConfig.Merge.Short())
+
+	upstream, err := repo.Reference(upstreamName, true)
+	if err != nil {
+		// The upstream branch has not been fetched yet.
+		return branch, nil
+	}
+
+	ahead, behind, err := aheadBehind(repo, head.Hash(), upstream.Hash())
+	if err != nil {
+		return Branch{}, err
+	}
+
+	branch.HasUpstream = true
+	branch.Ahead = ahead
+	branch.Behind = behind
+
+	return branch, nil
+}
+
+// Which of the two histories compared by aheadBehind a commit is within.
+const (
+	inLocal = 1 << iota
+	inUpstream
+	inBoth = inLocal | inUpstream
+)
+
+// queuedCommit is a commit waiting to be walked by aheadBehind.
+type queuedCommit struct {
+	commit *object.Commit
+	order  int
+}
+
+// commitQueue orders the commits still to be walked newest first, and those
+// made at the same time in the order they were queued like git does.
+type commitQueue []queuedCommit
+
+func (q commitQueue) Len() int { return len(q) }
+func (q commitQueue) Less(i, j int) bool {
+	a, b := q[i].commit.Committer.When, q[j].commit.Committer.When
+	if a.Equal(b) {
+		return q[i].order < q[j].order
+	}
+
+	return a.After(b)
+}
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *commitQueue) Push(x any)   { *q = append(*q, x.(queuedCommit)) }
+func (q *commitQueue) Pop() any {
+	old := *q
+	commit := old[len(old)-1]
+	*q = old[:len(old)-1]
+
+	return commit
+}
+
+// aheadBehind counts the commits of local which upstream doesn't have and
+// the other way round. Both histories are walked together newest first,
+// stopping once every commit left is within both, so only the commits since
+// they diverged are read rather than the whole history. A commit already
+// walked which turns out to be within the other history too passes that on
+// to the ancestors walked after it right away, like git does.
+func aheadBehind(repo *git.Repository, local, upstream plumbing.Hash) (int, int, error) {
+	flags := make(map[plumbing.Hash]int)
+	walked := make(map[plumbing.Hash][]plumbing.Hash)
+	queued := make(map[plumbing.Hash]bool)
+	queue := &commitQueue{}
+	order := 0
+
+	mark := func(hash plumbing.Hash, flag int) error {
+		pending := []plumbing.Hash{hash}
+
+		for len(pending) > 0 {
+			hash := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+
+			if flags[hash]|flag == flags[hash] {
+				continue
+			}
+
+			if parents, ok := walked[hash]; ok {
+				flags[hash] |= flag
+				pending = append(pending, parents...)
+
+				continue
+			}
+
+			if !queued[hash] {
+				commit, err := repo.CommitObject(hash)
+				if errors.Is(err, plumbing.ErrObjectNotFound) {
+					// The history of shallow clones ends early.
+					continue
+				}
+
+				if err != nil {
+					return err
+				}
+
+				queued[hash] = true
+				order++
+				heap.Push(queue, queuedCommit{commit: commit, order: order})
+			}
+
+			flags[hash] |= flag
+		}
+
+		return nil
+	}
+
+	if err := mark(local, inLocal); err != nil {
+		return 0, 0, err
+	}
+
+	if err := mark(upstream, inUpstream); err != nil {
+		return 0, 0, err
+	}
+
+	// The last commit walked which was only within one history. Commits made
+	// at the same time may be its ancestors, so they are walked too.
+	var lastMissing time.Time
+
+	for queue.Len() > 0 && !queue.allWithin(flags, lastMissing) {
+		commit := heap.Pop(queue).(queuedCommit).commit
+		delete(queued, commit.Hash)
+		walked[commit.Hash] = commit.ParentHashes
+
+		if flags[commit.Hash] != inBoth {
+			lastMissing = commit.Committer.When
+		}
+
+		for _, parent := range commit.ParentHashes {
+			if err := mark(parent, flags[commit.Hash]); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	ahead, behind := 0, 0
+
+	for _, flag := range flags {
+		switch flag {
+		case inLocal:
+			ahead++
+		case inUpstream:
+			behind++
+		}
+	}
+
+	return ahead, behind, nil
+}
+
+// allWithin reports whether every commit left to walk is within both
+// histories and older than the last commit which wasn't, in which case so
+// are all of their ancestors.
+func (q commitQueue) allWithin(flags map[plumbing.Hash]int, lastMissing time.Time) bool {
+	for _, queued := range q {
+		if flags[queued.commit.Hash] != inBoth {
+			return false
+		}
+	}
+
+	return lastMissing.IsZero() || q[0].commit.Committer.When.Before(lastMissing)
+}
@@ -0,0 +1,141 @@
+package gitrepo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// head returns the commit the head of a repository points at.
+func head(t *testing.T, repo *git.Repository) plumbing.Hash {
+	t.Helper()
+
+	ref, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ref.Hash()
+}
+
+// commitFile commits a change to a file on top of the parents provided, or
+// of the head when there are none, returning the new commit.
+func commitFile(t *testing.T, repo *git.Repository, root, content string, parents ...plumbing.Hash) plumbing.Hash {
+	t.Helper()
+
+	writeFile(t, root, "file.txt", content)
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := worktree.Add("file.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := worktree.Commit(content, &git.CommitOptions{
+		Author:  &object.Signature{Name: "Tester", Email: "tester@example.com", When: time.Now()},
+		Parents: parents,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+// setUpstream makes origin/master the upstream of master, pointing at the
+// commit provided.
+func setUpstream(t *testing.T, repo *git.Repository, hash plumbing.Hash) {
+	t.Helper()
+
+	cfg, err := repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Branches["master"] = &config.Branch{Name: "master", Remote: "origin", Merge: plumbing.NewBranchReferenceName("master")}
+
+	if err := repo.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	ref := plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", "master"), hash)
+	if err := repo.Storer.SetReference(ref); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadBranch(t *testing.T) {
+	root, repo := newRepository(t, map[string]string{"file.txt": "base"})
+
+	// Some history before the branches diverge, which isn't counted.
+	for _, content := range []string{"one", "two", "three"} {
+		commitFile(t, repo, root, content)
+	}
+
+	base := head(t, repo)
+	commitFile(t, repo, root, "upstream 1")
+	upstream := commitFile(t, repo, root, "upstream 2")
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := worktree.Reset(&git.ResetOptions{Commit: base, Mode: git.HardReset}); err != nil {
+		t.Fatal(err)
+	}
+
+	local := commitFile(t, repo, root, "local")
+	setUpstream(t, repo, upstream)
+
+	tests := []struct {
+		name   string
+		head   plumbing.Hash
+		ahead  int
+		behind int
+	}{
+		{name: "diverged", head: local, ahead: 1, behind: 2},
+		{name: "behind", head: base, ahead: 0, behind: 2},
+		{name: "up to date", head: upstream, ahead: 0, behind: 0},
+		{name: "merged", head: commitFile(t, repo, root, "merge", local, upstream), ahead: 2, behind: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName("master"), test.head)
+			if err := repo.Storer.SetReference(ref); err != nil {
+				t.Fatal(err)
+			}
+
+			branch, err := readBranch(repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := Branch{Name: "master", Ahead: test.ahead, Behind: test.behind, HasUpstream: true}
+			if branch != want {
+				t.Errorf("readBranch() = %+v, want %+v", branch, want)
+			}
+		})
+	}
+}
+
+func TestReadBranchWithoutUpstream(t *testing.T) {
+	_, repo := newRepository(t, map[string]string{"file.txt": "base"})
+
+	branch, err := readBranch(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (Branch{Name: "master"}); branch != want {
+		t.Errorf("readBranch() = %+v, want %+v", branch, want)
+	}
+}
@@ -0,0 +1,309 @@
+// Package journal records the file operations performed so that they can be
+// undone and redone.
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mistakenelf/fm/filesystem"
+	"github.com/mistakenelf/fm/internal/trash"
+)
+
+// ErrNothingToUndo is returned when there is no operation left to undo.
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// ErrNothingToRedo is returned when there is no operation left to redo.
+var ErrNothingToRedo = errors.New("nothing to redo")
+
+type actionKind int
+
+const (
+	movedAction actionKind = iota
+	createdAction
+	trashedAction
+)
+
+// fileState is what a file looked like right after an action, used to tell
+// whether it was changed since.
+type fileState struct {
+	mode    fs.FileMode
+	size    int64
+	modTime time.Time
+}
+
+// Action is a change to a single file or directory.
+type Action struct {
+	kind actionKind
+	// path is the file moved, created or trashed.
+	path string
+	// destination is where a moved file went.
+	destination string
+	// infoPath is the info file of a trashed file.
+	infoPath string
+	// current is where the file is now, and state what it looked like.
+	current string
+	state   fileState
+}
+
+// Moved returns the action of a file being moved or renamed.
+func Moved(source, destination string) Action {
+	return newAction(Action{kind: movedAction, path: source, destination: destination, current: destination})
+}
+
+// Created returns the action of a file being created, which includes copies,
+// links and extracted archives.
+func Created(path string) Action {
+	return newAction(Action{kind: createdAction, path: path, current: path})
+}
+
+// Trashed returns the action of a file being moved to the trash.
+func Trashed(item trash.Item) Action {
+	return newAction(Action{kind: trashedAction, path: item.OriginalPath, infoPath: item.InfoPath, current: item.Path})
+}
+
+// newAction makes the paths of an action absolute and records the state of
+// the file it left behind.
+func newAction(action Action) Action {
+	for _, path := range []*string{&action.path, &action.destination, &action.current} {
+		if *path == "" {
+			continue
+		}
+
+		if absolutePath, err := filepath.Abs(*path); err == nil {
+			*path = absolutePath
+		}
+	}
+
+	action.state, _ = stateOf(action.current)
+
+	return action
+}
+
+// stateOf returns the state of the file at path.
+func stateOf(path string) (fileState, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+
+	return fileState{mode: info.Mode(), size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+// check returns an error when the file was changed since the action or when
+// reversing it would replace another file. Files at the paths freed are moved
+// away before the action is reversed.
+func (a Action) check(freed map[string]bool) error {
+	state, err := stateOf(a.current)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s no longer exists", a.displayPath())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if state != a.state {
+		return fmt.Errorf("%s has changed since", a.displayPath())
+	}
+
+	// Moved and trashed files go back to where they were.
+	if a.kind == createdAction {
+		return nil
+	}
+
+	if _, err := os.Lstat(a.path); err == nil && !freed[a.path] {
+		return fmt.Errorf("%s already exists", a.path)
+	}
+
+	return nil
+}
+
+// displayPath returns the path of the file an action is about.
+func (a Action) displayPath() string {
+	if a.kind == trashedAction {
+		return filepath.Base(a.path) + " in the trash"
+	}
+
+	return a.current
+}
+
+// reverse reverses the action, returning the action which reverses it back.
+// Moved files are moved back the way they were moved, which may be onto
+// another device. Created files are moved to the trash so that they can be
+// restored later.
+func (a Action) reverse() (Action, error) {
+	switch a.kind {
+	case movedAction:
+		if err := os.MkdirAll(filepath.Dir(a.path), 0o755); err != nil {
+			return Action{}, err
+		}
+
+		if _, err := filesystem.MoveDirectoryItem(a.destination, a.path, filesystem.ConflictHandler{}); err != nil {
+			return Action{}, err
+		}
+
+		return Moved(a.destination, a.path), nil
+	case createdAction:
+		item, err := trash.Trash(a.path)
+		if err != nil {
+			return Action{}, err
+		}
+
+		return Trashed(item), nil
+	default:
+		item, err := trash.Read(a.infoPath)
+		if err != nil {
+			return Action{}, err
+		}
+
+		if err := trash.Restore(item); err != nil {
+			return Action{}, err
+		}
+
+		return Created(a.path), nil
+	}
+}
+
+// Operation is a set of actions performed by a single command.
+type Operation struct {
+	Name    string
+	Actions []Action
+}
+
+// Description describes the operation, such as "move of notes.txt". Files
+// trashed to make way for others are left out.
+func (o Operation) Description() string {
+	var actions []Action
+
+	for _, action := range o.Actions {
+		if action.kind != trashedAction {
+			actions = append(actions, action)
+		}
+	}
+
+	if len(actions) == 0 {
+		actions = o.Actions
+	}
+
+	if len(actions) == 1 {
+		return fmt.Sprintf("%s of %s", o.Name, filepath.Base(actions[0].path))
+	}
+
+	return fmt.Sprintf("%s of %d items", o.Name, len(actions))
+}
+
+// reverse checks that none of the files of the operation were changed since
+// and reverses its actions, last first. When reversing stops partway the
+// reversed actions are returned along with the error, and the operation is
+// left with the actions still to reverse.
+func (o *Operation) reverse() (Operation, error) {
+	freed := make(map[string]bool)
+
+	for i := len(o.Actions) - 1; i >= 0; i-- {
+		if err := o.Actions[i].check(freed); err != nil {
+			return Operation{}, fmt.Errorf("can't reverse %s: %w", o.Description(), err)
+		}
+
+		freed[o.Actions[i].current] = true
+	}
+
+	reversed := Operation{Name: o.Name}
+
+	for len(o.Actions) > 0 {
+		last := len(o.Actions) - 1
+
+		action, err := o.Actions[last].reverse()
+		if err != nil {
+			return reversed, err
+		}
+
+		reversed.Actions = append(reversed.Actions, action)
+		o.Actions = o.Actions[:last]
+	}
+
+	return reversed, nil
+}
+
+// Journal holds the operations which can be undone and redone. It is safe
+// for concurrent use.
+type Journal struct {
+	mu   sync.Mutex
+	undo []Operation
+	redo []Operation
+}
+
+// New creates a new empty journal.
+func New() *Journal {
+	return &Journal{}
+}
+
+// Record adds an operation which can be undone, forgetting the operations
+// which could be redone. Operations without actions are not recorded, and
+// nothing is recorded by a nil journal.
+func (j *Journal) Record(name string, actions ...Action) {
+	if j == nil || len(actions) == 0 {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.undo = append(j.undo, Operation{Name: name, Actions: actions})
+	j.redo = nil
+}
+
+// Undo reverses the last operation, returning its description.
+func (j *Journal) Undo() (string, error) {
+	if j == nil {
+		return "", ErrNothingToUndo
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return move(&j.undo, &j.redo, ErrNothingToUndo)
+}
+
+// Redo performs the last undone operation again, returning its description.
+func (j *Journal) Redo() (string, error) {
+	if j == nil {
+		return "", ErrNothingToRedo
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return move(&j.redo, &j.undo, ErrNothingToRedo)
+}
+
+// move reverses the last operation of from and adds the reversed operation
+// to to.
+func move(from, to *[]Operation, empty error) (string, error) {
+	if len(*from) == 0 {
+		return "", empty
+	}
+
+	last := len(*from) - 1
+	operation := (*from)[last]
+	description := operation.Description()
+
+	reversed, err := operation.reverse()
+
+	if len(operation.Actions) == 0 {
+		*from = (*from)[:last]
+	} else {
+		(*from)[last] = operation
+	}
+
+	if len(reversed.Actions) > 0 {
+		*to = append(*to, reversed)
+	}
+
+	return description, err
+}
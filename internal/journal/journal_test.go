@@ -0,0 +1,325 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mistakenelf/fm/internal/trash"
+)
+
+// setDataHome points the home trash at a temporary directory.
+func setDataHome(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+}
+
+// writeFile writes a file, creating its directories.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// rename renames a file, creating the directory it is moved to.
+func rename(t *testing.T, src, dst string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Rename(src, dst); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assertContent fails the test when the file at path doesn't hold content.
+func assertContent(t *testing.T, path, content string) {
+	t.Helper()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("reading %s: %v", path, err)
+
+		return
+	}
+
+	if string(got) != content {
+		t.Errorf("content of %s = %q, want %q", path, got, content)
+	}
+}
+
+// assertMissing fails the test when something exists at path.
+func assertMissing(t *testing.T, path string) {
+	t.Helper()
+
+	if _, err := os.Lstat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("%s exists, want it gone", path)
+	}
+}
+
+func TestUndoRename(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.txt")
+	dst := filepath.Join(dir, "b.txt")
+	writeFile(t, src, "content")
+	rename(t, src, dst)
+
+	j := New()
+	j.Record("rename", Moved(src, dst))
+
+	description, err := j.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if description != "rename of a.txt" {
+		t.Errorf("description = %q, want %q", description, "rename of a.txt")
+	}
+
+	assertContent(t, src, "content")
+	assertMissing(t, dst)
+
+	if _, err := j.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("second Undo() = %v, want %v", err, ErrNothingToUndo)
+	}
+
+	if _, err := j.Redo(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, dst, "content")
+	assertMissing(t, src)
+
+	if _, err := j.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("second Redo() = %v, want %v", err, ErrNothingToRedo)
+	}
+
+	// Undoing again after redoing moves the file back once more.
+	if _, err := j.Undo(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, src, "content")
+}
+
+func TestUndoOverwrite(t *testing.T) {
+	setDataHome(t)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src", "notes.txt")
+	dst := filepath.Join(dir, "dst", "notes.txt")
+	writeFile(t, src, "new")
+	writeFile(t, dst, "old")
+
+	item, err := trash.Trash(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rename(t, src, dst)
+
+	j := New()
+	j.Record("move", Trashed(item), Moved(src, dst))
+
+	description, err := j.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if description != "move of notes.txt" {
+		t.Errorf("description = %q, want %q", description, "move of notes.txt")
+	}
+
+	assertContent(t, src, "new")
+	assertContent(t, dst, "old")
+	assertMissing(t, item.Path)
+
+	if _, err := j.Redo(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, dst, "new")
+	assertMissing(t, src)
+
+	// The overwritten file is back in the trash, so undoing restores it again.
+	if _, err := j.Undo(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, src, "new")
+	assertContent(t, dst, "old")
+}
+
+func TestUndoCreated(t *testing.T) {
+	setDataHome(t)
+
+	path := filepath.Join(t.TempDir(), "copy.txt")
+	writeFile(t, path, "content")
+
+	j := New()
+	j.Record("copy", Created(path))
+
+	if _, err := j.Undo(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertMissing(t, path)
+
+	if _, err := j.Redo(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, path, "content")
+}
+
+func TestUndoChangedSince(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, src, dst string)
+	}{
+		{
+			name: "modified",
+			change: func(t *testing.T, _, dst string) {
+				writeFile(t, dst, "modified content")
+			},
+		},
+		{
+			name: "removed",
+			change: func(t *testing.T, _, dst string) {
+				if err := os.Remove(dst); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "source taken",
+			change: func(t *testing.T, src, _ string) {
+				writeFile(t, src, "another file")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "a.txt")
+			dst := filepath.Join(dir, "b.txt")
+			writeFile(t, src, "content")
+			rename(t, src, dst)
+
+			j := New()
+			j.Record("rename", Moved(src, dst))
+			test.change(t, src, dst)
+
+			before, _ := os.ReadFile(src)
+
+			if _, err := j.Undo(); err == nil {
+				t.Fatal("undoing a rename of a file changed since succeeded")
+			}
+
+			after, _ := os.ReadFile(src)
+			if string(after) != string(before) {
+				t.Errorf("content of %s = %q after refusing to undo, want %q", src, after, before)
+			}
+
+			// Nothing was reversed, so there is nothing to redo and the rename
+			// can still be undone.
+			if _, err := j.Redo(); !errors.Is(err, ErrNothingToRedo) {
+				t.Errorf("Redo() = %v, want %v", err, ErrNothingToRedo)
+			}
+
+			if len(j.undo) != 1 || len(j.undo[0].Actions) != 1 {
+				t.Errorf("undo = %+v, want the rename left to undo", j.undo)
+			}
+		})
+	}
+}
+
+func TestUndoPartialFailure(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "sub", "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	movedA := filepath.Join(dir, "dst", "a.txt")
+	movedB := filepath.Join(dir, "dst", "b.txt")
+
+	writeFile(t, a, "a")
+	writeFile(t, b, "b")
+	rename(t, a, movedA)
+	rename(t, b, movedB)
+
+	j := New()
+	j.Record("move", Moved(a, movedA), Moved(b, movedB))
+
+	// A file where the directory of a.txt was makes moving it back fail once
+	// b.txt, which is moved back first, has been.
+	if err := os.Remove(filepath.Dir(a)); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Dir(a), "obstacle")
+
+	if _, err := j.Undo(); err == nil {
+		t.Fatal("undoing a move whose directory was replaced by a file succeeded")
+	}
+
+	assertContent(t, b, "b")
+	assertMissing(t, movedB)
+	assertContent(t, movedA, "a")
+
+	if len(j.undo) != 1 || len(j.undo[0].Actions) != 1 || j.undo[0].Actions[0].current != movedA {
+		t.Errorf("undo = %+v, want the move of a.txt left to undo", j.undo)
+	}
+
+	if len(j.redo) != 1 || len(j.redo[0].Actions) != 1 || j.redo[0].Actions[0].current != b {
+		t.Errorf("redo = %+v, want the move of b.txt to redo", j.redo)
+	}
+
+	// Once the obstacle is gone the rest of the move is undone.
+	if err := os.Remove(filepath.Dir(a)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := j.Undo(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, a, "a")
+	assertMissing(t, movedA)
+
+	if _, err := j.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Undo() = %v, want %v", err, ErrNothingToUndo)
+	}
+
+	for range 2 {
+		if _, err := j.Redo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertContent(t, movedA, "a")
+	assertContent(t, movedB, "b")
+}
+
+func TestNilJournal(t *testing.T) {
+	var j *Journal
+
+	j.Record("rename", Moved("a", "b"))
+
+	if _, err := j.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Undo() = %v, want %v", err, ErrNothingToUndo)
+	}
+
+	if _, err := j.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("Redo() = %v, want %v", err, ErrNothingToRedo)
+	}
+}
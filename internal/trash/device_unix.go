@@ -0,0 +1,18 @@
+//go:build !windows
+
+package trash
+
+import (
+	"os"
+	"syscall"
+)
+
+// deviceID returns the id of the device a file is on.
+func deviceID(info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+
+	return uint64(stat.Dev), true //nolint:unconvert // Dev is not 64 bit on every platform.
+}
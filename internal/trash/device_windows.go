@@ -0,0 +1,10 @@
+//go:build windows
+
+package trash
+
+import "os"
+
+// deviceID is not supported on windows, where everything uses the home trash.
+func deviceID(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,471 @@
+// Package trash moves files to the trash following the freedesktop.org trash
+// specification, and lists, restores and purges the files within it.
+package trash
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mistakenelf/fm/filesystem"
+)
+
+const (
+	infoExtension = ".trashinfo"
+	infoHeader    = "[Trash Info]"
+	dateLayout    = "2006-01-02T15:04:05"
+	mountsFile    = "/proc/self/mounts"
+)
+
+// Item is a file in the trash.
+type Item struct {
+	Name         string
+	Path         string
+	InfoPath     string
+	OriginalPath string
+	DeletionDate time.Time
+	IsDirectory  bool
+}
+
+// directory is a trash directory. Files trashed to the trash directory at
+// the top of a mount record their original path relative to the top of it.
+type directory struct {
+	path         string
+	topDirectory string
+}
+
+func (d directory) filesPath() string { return filepath.Join(d.path, "files") }
+func (d directory) infoPath() string  { return filepath.Join(d.path, "info") }
+
+// homeDirectory returns the trash directory in the data home of the user.
+func homeDirectory() (directory, error) {
+	dataHome, err := filesystem.GetDataHome()
+	if err != nil {
+		return directory{}, err
+	}
+
+	return directory{path: filepath.Join(dataHome, "Trash")}, nil
+}
+
+// directoryOf returns the trash directory an info file belongs to.
+func directoryOf(infoPath string) directory {
+	path := filepath.Dir(filepath.Dir(infoPath))
+
+	home, err := homeDirectory()
+	if err == nil && home.path == path {
+		return home
+	}
+
+	// Either $topdir/.Trash/$uid or $topdir/.Trash-$uid.
+	parent := filepath.Dir(path)
+	if filepath.Base(parent) == ".Trash" {
+		return directory{path: path, topDirectory: filepath.Dir(parent)}
+	}
+
+	return directory{path: path, topDirectory: parent}
+}
+
+// directoryFor returns the trash directory the file at path is moved to. The
+// home trash is used for files on the same device as it, otherwise files go
+// to a trash directory at the top of the mount they are on.
+func directoryFor(path string) (directory, error) {
+	home, err := homeDirectory()
+	if err != nil {
+		return directory{}, err
+	}
+
+	if err := os.MkdirAll(home.path, 0o700); err != nil {
+		return directory{}, err
+	}
+
+	pathDevice, ok := device(path)
+	if !ok {
+		return home, nil
+	}
+
+	homeDevice, ok := device(home.path)
+	if !ok || homeDevice == pathDevice {
+		return home, nil
+	}
+
+	return topDirectory(mountTop(path, pathDevice))
+}
+
+// device returns the id of the device the file at path is on.
+func device(path string) (uint64, bool) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, false
+	}
+
+	return deviceID(info)
+}
+
+// mountTop returns the top directory of the mount containing path, which is
+// on the given device.
+func mountTop(path string, pathDevice uint64) string {
+	dir := filepath.Dir(path)
+
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+
+		if parentDevice, ok := device(parent); !ok || parentDevice != pathDevice {
+			return dir
+		}
+
+		dir = parent
+	}
+}
+
+// topDirectory returns the trash directory of the user at the top of a
+// mount, creating it when needed. A shared .Trash directory is used when an
+// administrator set one up, otherwise the user gets a .Trash-$uid of their own.
+func topDirectory(top string) (directory, error) {
+	uid := strconv.Itoa(os.Getuid())
+
+	// The shared directory must not be a symlink and must have the sticky bit set.
+	shared := filepath.Join(top, ".Trash")
+	if info, err := os.Lstat(shared); err == nil && info.IsDir() && info.Mode()&os.ModeSticky != 0 {
+		path := filepath.Join(shared, uid)
+		if err := os.MkdirAll(path, 0o700); err == nil {
+			return directory{path: path, topDirectory: top}, nil
+		}
+	}
+
+	path := filepath.Join(top, ".Trash-"+uid)
+	if err := os.MkdirAll(path, 0o700); err != nil {
+		return directory{}, err
+	}
+
+	return directory{path: path, topDirectory: top}, nil
+}
+
+// Trash moves the file or directory at path to the trash, returning the item
+// it became.
+func Trash(path string) (Item, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return Item{}, err
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return Item{}, err
+	}
+
+	dir, err := directoryFor(path)
+	if err != nil {
+		return Item{}, err
+	}
+
+	for _, subdirectory := range []string{dir.filesPath(), dir.infoPath()} {
+		if err := os.MkdirAll(subdirectory, 0o700); err != nil {
+			return Item{}, err
+		}
+	}
+
+	originalPath := path
+	if dir.topDirectory != "" {
+		if relativePath, err := filepath.Rel(dir.topDirectory, path); err == nil {
+			originalPath = relativePath
+		}
+	}
+
+	infoFile, name, err := createInfoFile(dir, filepath.Base(path))
+	if err != nil {
+		return Item{}, err
+	}
+
+	item := Item{
+		Name:         name,
+		Path:         filepath.Join(dir.filesPath(), name),
+		InfoPath:     infoFile.Name(),
+		OriginalPath: path,
+		DeletionDate: time.Now().Truncate(time.Second),
+		IsDirectory:  info.IsDir(),
+	}
+
+	_, err = fmt.Fprintf(
+		infoFile,
+		"%s\nPath=%s\nDeletionDate=%s\n",
+		infoHeader,
+		(&url.URL{Path: originalPath}).EscapedPath(),
+		item.DeletionDate.Format(dateLayout),
+	)
+
+	if closeErr := infoFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(path, item.Path)
+	}
+
+	if err != nil {
+		_ = os.Remove(item.InfoPath)
+
+		return Item{}, err
+	}
+
+	return item, nil
+}
+
+// createInfoFile creates the info file of a file being trashed, picking a
+// name which no other file in the trash uses.
+func createInfoFile(dir directory, base string) (*os.File, string, error) {
+	extension := filepath.Ext(base)
+	if extension == base {
+		extension = ""
+	}
+
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf("%s.%d%s", strings.TrimSuffix(base, extension), i, extension)
+		}
+
+		infoPath := filepath.Join(dir.infoPath(), name+infoExtension)
+
+		file, err := os.OpenFile(infoPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		// A file can be left in the trash without its info file after a crash.
+		if _, err := os.Lstat(filepath.Join(dir.filesPath(), name)); err == nil {
+			_ = file.Close()
+			_ = os.Remove(infoPath)
+
+			continue
+		}
+
+		return file, name, nil
+	}
+}
+
+// Read returns the item described by the info file at the path provided.
+func Read(infoPath string) (Item, error) {
+	dir := directoryOf(infoPath)
+	name := strings.TrimSuffix(filepath.Base(infoPath), infoExtension)
+
+	item := Item{
+		Name:     name,
+		Path:     filepath.Join(dir.filesPath(), name),
+		InfoPath: infoPath,
+	}
+
+	info, err := os.Lstat(item.Path)
+	if err != nil {
+		return Item{}, err
+	}
+
+	item.IsDirectory = info.IsDir()
+
+	file, err := os.Open(filepath.Clean(infoPath))
+	if err != nil {
+		return Item{}, err
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "Path":
+			originalPath, err := url.PathUnescape(value)
+			if err != nil {
+				return Item{}, fmt.Errorf("reading %s: %w", infoPath, err)
+			}
+
+			if !filepath.IsAbs(originalPath) {
+				originalPath = filepath.Join(dir.topDirectory, originalPath)
+			}
+
+			item.OriginalPath = originalPath
+		case "DeletionDate":
+			item.DeletionDate, _ = time.ParseInLocation(dateLayout, value, time.Local)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return Item{}, err
+	}
+
+	if item.OriginalPath == "" {
+		return Item{}, fmt.Errorf("reading %s: no original path", infoPath)
+	}
+
+	return item, nil
+}
+
+// items returns the items within a trash directory, skipping those whose
+// info can't be read.
+func (d directory) items() ([]Item, error) {
+	entries, err := os.ReadDir(d.infoPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(entries))
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), infoExtension) {
+			continue
+		}
+
+		item, err := Read(filepath.Join(d.infoPath(), entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// mountDirectories returns the trash directories of the user at the top of
+// each mount. Mounts can only be found where /proc is available.
+func mountDirectories() []directory {
+	file, err := os.Open(mountsFile)
+	if err != nil {
+		return nil
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	uid := strconv.Itoa(os.Getuid())
+
+	var directories []directory
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		top := unescapeMountPath(fields[1])
+
+		for _, path := range []string{filepath.Join(top, ".Trash", uid), filepath.Join(top, ".Trash-"+uid)} {
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				directories = append(directories, directory{path: path, topDirectory: top})
+			}
+		}
+	}
+
+	return directories
+}
+
+// unescapeMountPath decodes the octal escapes used for spaces and other
+// special characters in the mounts file.
+func unescapeMountPath(path string) string {
+	var unescaped strings.Builder
+
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if value, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				unescaped.WriteByte(byte(value))
+				i += 3
+
+				continue
+			}
+		}
+
+		unescaped.WriteByte(path[i])
+	}
+
+	return unescaped.String()
+}
+
+// List returns the items in the home trash and the trash directories of each
+// mount, most recently deleted first.
+func List() ([]Item, error) {
+	home, err := homeDirectory()
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := home.items()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]struct{}{home.path: {}}
+
+	for _, dir := range mountDirectories() {
+		if _, ok := seen[dir.path]; ok {
+			continue
+		}
+
+		seen[dir.path] = struct{}{}
+
+		mountItems, err := dir.items()
+		if err != nil {
+			continue
+		}
+
+		items = append(items, mountItems...)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DeletionDate.After(items[j].DeletionDate)
+	})
+
+	return items, nil
+}
+
+// Restore moves an item back to where it was trashed from. Nothing is
+// overwritten when something else is there now.
+func Restore(item Item) error {
+	if _, err := os.Lstat(item.OriginalPath); err == nil {
+		return fmt.Errorf("%s already exists", item.OriginalPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(item.OriginalPath), 0o755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(item.Path, item.OriginalPath); err != nil {
+		return err
+	}
+
+	return os.Remove(item.InfoPath)
+}
+
+// Purge permanently deletes an item from the trash.
+func Purge(item Item) error {
+	if err := os.RemoveAll(item.Path); err != nil {
+		return err
+	}
+
+	return os.Remove(item.InfoPath)
+}
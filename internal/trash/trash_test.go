@@ -0,0 +1,294 @@
+package trash
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setDataHome points the home trash at a temporary directory, returning the
+// path of the trash.
+func setDataHome(t *testing.T) string {
+	t.Helper()
+
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+
+	return filepath.Join(dataHome, "Trash")
+}
+
+// writeFile writes a file, creating its directories.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	trashPath := setDataHome(t)
+	path := filepath.Join(t.TempDir(), "a file%.txt")
+	writeFile(t, path, "content")
+
+	item, err := Trash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("%s is still there after being trashed", path)
+	}
+
+	wantPath := filepath.Join(trashPath, "files", "a file%.txt")
+	if item.Path != wantPath {
+		t.Errorf("item path = %s, want %s", item.Path, wantPath)
+	}
+
+	if content, err := os.ReadFile(wantPath); err != nil || string(content) != "content" {
+		t.Errorf("content of %s = %q, %v, want %q", wantPath, content, err, "content")
+	}
+
+	info, err := os.ReadFile(filepath.Join(trashPath, "info", "a file%.txt.trashinfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantInfo := "[Trash Info]\n" +
+		"Path=" + strings.ReplaceAll(filepath.ToSlash(filepath.Dir(path)), " ", "%20") + "/a%20file%25.txt\n" +
+		"DeletionDate=" + item.DeletionDate.Format("2006-01-02T15:04:05") + "\n"
+	if string(info) != wantInfo {
+		t.Errorf("info file = %q, want %q", info, wantInfo)
+	}
+
+	read, err := Read(item.InfoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if read != item {
+		t.Errorf("Read(%s) = %+v, want %+v", item.InfoPath, read, item)
+	}
+}
+
+func TestTrashNameCollisions(t *testing.T) {
+	tests := []struct {
+		base  string
+		names []string
+	}{
+		{base: "notes.txt", names: []string{"notes.txt", "notes.2.txt", "notes.3.txt"}},
+		{base: "README", names: []string{"README", "README.2"}},
+		{base: ".bashrc", names: []string{".bashrc", ".bashrc.2"}},
+		{base: "archive.tar.gz", names: []string{"archive.tar.gz", "archive.tar.2.gz"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.base, func(t *testing.T) {
+			setDataHome(t)
+
+			for _, want := range test.names {
+				path := filepath.Join(t.TempDir(), test.base)
+				writeFile(t, path, want)
+
+				item, err := Trash(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if item.Name != want {
+					t.Errorf("name in the trash = %s, want %s", item.Name, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTrashSkipsOrphanedFiles(t *testing.T) {
+	trashPath := setDataHome(t)
+	writeFile(t, filepath.Join(trashPath, "files", "notes.txt"), "orphaned")
+
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	writeFile(t, path, "content")
+
+	item, err := Trash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Name != "notes.2.txt" {
+		t.Errorf("name in the trash = %s, want notes.2.txt", item.Name)
+	}
+
+	if _, err := os.Lstat(filepath.Join(trashPath, "info", "notes.txt.trashinfo")); !os.IsNotExist(err) {
+		t.Error("an info file was left for the orphaned file")
+	}
+
+	if content, err := os.ReadFile(filepath.Join(trashPath, "files", "notes.txt")); err != nil || string(content) != "orphaned" {
+		t.Errorf("orphaned file = %q, %v, want it left alone", content, err)
+	}
+}
+
+func TestReadTopDirectory(t *testing.T) {
+	setDataHome(t)
+
+	top := t.TempDir()
+	uid := strconv.Itoa(os.Getuid())
+
+	tests := []struct {
+		name      string
+		directory string
+	}{
+		{name: "own directory", directory: filepath.Join(top, ".Trash-"+uid)},
+		{name: "shared directory", directory: filepath.Join(top, ".Trash", uid)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infoPath := filepath.Join(test.directory, "info", "x y.trashinfo")
+			writeFile(t, filepath.Join(test.directory, "files", "x y"), "content")
+			writeFile(t, infoPath, "[Trash Info]\nPath=dir/x%20y\nDeletionDate=2024-05-06T07:08:09\n")
+
+			item, err := Read(infoPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := filepath.Join(top, "dir", "x y"); item.OriginalPath != want {
+				t.Errorf("original path = %s, want %s", item.OriginalPath, want)
+			}
+
+			want := time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local)
+			if !item.DeletionDate.Equal(want) {
+				t.Errorf("deletion date = %s, want %s", item.DeletionDate, want)
+			}
+		})
+	}
+}
+
+func TestReadWithoutPath(t *testing.T) {
+	trashPath := setDataHome(t)
+	infoPath := filepath.Join(trashPath, "info", "x.trashinfo")
+	writeFile(t, filepath.Join(trashPath, "files", "x"), "")
+	writeFile(t, infoPath, "[Trash Info]\nDeletionDate=2024-05-06T07:08:09\n")
+
+	if _, err := Read(infoPath); err == nil {
+		t.Error("reading an info file without a path succeeded")
+	}
+}
+
+func TestUnescapeMountPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/mnt/disk", want: "/mnt/disk"},
+		{path: `/mnt/my\040disk`, want: "/mnt/my disk"},
+		{path: `/mnt/tab\011and\134slash`, want: "/mnt/tab\tand\\slash"},
+		{path: `/mnt/not\09an\escape`, want: `/mnt/not\09an\escape`},
+		{path: `/mnt/end\04`, want: `/mnt/end\04`},
+	}
+
+	for _, test := range tests {
+		if got := unescapeMountPath(test.path); got != test.want {
+			t.Errorf("unescapeMountPath(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	setDataHome(t)
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	writeFile(t, path, "content")
+
+	item, err := Trash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, path, "replacement")
+
+	if err := Restore(item); err == nil {
+		t.Error("restoring over an existing file succeeded")
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Restore(item); err != nil {
+		t.Fatal(err)
+	}
+
+	if content, err := os.ReadFile(path); err != nil || string(content) != "content" {
+		t.Errorf("restored content = %q, %v, want %q", content, err, "content")
+	}
+
+	if _, err := os.Lstat(item.InfoPath); !os.IsNotExist(err) {
+		t.Error("the info file was left after restoring")
+	}
+}
+
+func TestPurge(t *testing.T) {
+	setDataHome(t)
+	path := filepath.Join(t.TempDir(), "dir")
+	writeFile(t, filepath.Join(path, "notes.txt"), "content")
+
+	item, err := Trash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !item.IsDirectory {
+		t.Error("a trashed directory isn't a directory")
+	}
+
+	if err := Purge(item); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{item.Path, item.InfoPath} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%s is still there after purging", path)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	trashPath := setDataHome(t)
+
+	for name, date := range map[string]string{
+		"old":    "2024-01-01T00:00:00",
+		"newest": "2024-03-01T00:00:00",
+		"middle": "2024-02-01T00:00:00",
+	} {
+		writeFile(t, filepath.Join(trashPath, "files", name), "")
+		writeFile(t, filepath.Join(trashPath, "info", name+".trashinfo"), "[Trash Info]\nPath=/"+name+"\nDeletionDate="+date+"\n")
+	}
+
+	// Info files without a file in the trash are left out.
+	writeFile(t, filepath.Join(trashPath, "info", "missing.trashinfo"), "[Trash Info]\nPath=/missing\n")
+
+	items, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+
+	for _, item := range items {
+		if strings.HasPrefix(item.InfoPath, trashPath) {
+			names = append(names, item.Name)
+		}
+	}
+
+	if got := strings.Join(names, ","); got != "newest,middle,old" {
+		t.Errorf("items = %s, want newest,middle,old", got)
+	}
+}
@@ -1,10 +1,17 @@
 package tui
 
 import (
+	"fmt"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+
+	"github.com/mistakenelf/fm/filetree"
+	"github.com/mistakenelf/fm/internal/bookmarks"
+	"github.com/mistakenelf/fm/internal/frecency"
+	"github.com/mistakenelf/fm/internal/gitrepo"
+	"github.com/mistakenelf/fm/internal/trash"
 	"github.com/mistakenelf/fm/polish"
 )
 
@@ -26,12 +33,23 @@ var forbiddenExtensions = []string{
 }
 
 type statusMessageTimeoutMsg struct{}
+type errorMsg string
+type bookmarkSavedMsg string
+type bookmarksMsg []bookmarks.Bookmark
+type trashMsg []trash.Item
+type jumpToDirectoryMsg string
+type gitDiffMsg string
+type gitBlameMsg string
 
 func (m *model) openFileCmd() tea.Cmd {
-	selectedFile := m.filetree.GetSelectedItem()
+	return m.previewFileCmd(m.filetree.GetSelectedItem())
+}
 
+// previewFileCmd shows the file provided in the preview pane.
+func (m *model) previewFileCmd(selectedFile filetree.DirectoryItem) tea.Cmd {
 	if !selectedFile.IsDirectory {
 		m.resetViewports()
+		m.previewItem = selectedFile
 
 		switch {
 		case selectedFile.Extension == ".csv":
@@ -65,6 +83,178 @@ func (m *model) openFileCmd() tea.Cmd {
 	return nil
 }
 
+// previewSelectionCmd shows a live preview of the selected item in the miller
+// layout, listing the contents of directories and rendering files.
+func (m *model) previewSelectionCmd(selectedItem filetree.DirectoryItem) tea.Cmd {
+	switch {
+	case selectedItem.Path == "":
+		m.state = idleState
+
+		return nil
+	case selectedItem.IsBrokenLink:
+		m.state = idleState
+
+		return nil
+	case selectedItem.IsDirectory:
+		m.state = showDirectoryPreviewState
+
+		return m.previewFiletree.GetDirectoryListingCmd(selectedItem.Path)
+	case contains(forbiddenExtensions, selectedItem.Extension):
+		m.state = idleState
+
+		return nil
+	default:
+		return m.openFileCmd()
+	}
+}
+
+// syncMillerColumnsCmd keeps the parent and preview columns of the miller
+// layout in sync with the primary filetree.
+func (m *model) syncMillerColumnsCmd() tea.Cmd {
+	var cmds []tea.Cmd
+
+	if m.config.Layout != MillerLayout || m.filetree.CurrentDirectory == "" {
+		return nil
+	}
+
+	if m.parentDirectory != m.filetree.CurrentDirectory {
+		m.parentDirectory = m.filetree.CurrentDirectory
+		cmds = append(cmds, m.parentFiletree.SelectPathCmd(m.filetree.CurrentDirectory))
+	}
+
+	switch m.state {
+	case idleState, showCodeState, showImageState, showMarkdownState,
+		showPdfState, showCsvState, showDirectoryPreviewState:
+		selectedItem := m.filetree.GetSelectedItem()
+
+		if m.activePane == 0 && selectedItem.Path != m.previewPath {
+			m.previewPath = selectedItem.Path
+			cmds = append(cmds, m.previewSelectionCmd(selectedItem))
+		}
+	}
+
+	return tea.Batch(cmds...)
+}
+
+// loadTrashCmd reads the items in the trash to show in the trash list.
+func loadTrashCmd() tea.Cmd {
+	return func() tea.Msg {
+		items, err := trash.List()
+		if err != nil {
+			return errorMsg(err.Error())
+		}
+
+		return trashMsg(items)
+	}
+}
+
+// trashActionCmd restores or purges the item of the trash described by the
+// info file provided and then reads the trash again.
+func trashActionCmd(infoPath string, action func(trash.Item) error) tea.Cmd {
+	return func() tea.Msg {
+		item, err := trash.Read(infoPath)
+		if err != nil {
+			return errorMsg(err.Error())
+		}
+
+		if err := action(item); err != nil {
+			return errorMsg(err.Error())
+		}
+
+		return loadTrashCmd()()
+	}
+}
+
+// loadBookmarksCmd reads the bookmarks to show in the bookmarks list.
+func loadBookmarksCmd() tea.Cmd {
+	return func() tea.Msg {
+		store, err := bookmarks.Load()
+		if err != nil {
+			return errorMsg(err.Error())
+		}
+
+		return bookmarksMsg(store.List())
+	}
+}
+
+// setBookmarkCmd bookmarks the directory under the given name.
+func setBookmarkCmd(name, directory string) tea.Cmd {
+	return func() tea.Msg {
+		store, err := bookmarks.Load()
+		if err != nil {
+			return errorMsg(err.Error())
+		}
+
+		if err := store.Set(name, directory); err != nil {
+			return errorMsg(err.Error())
+		}
+
+		if err := store.Save(); err != nil {
+			return errorMsg(err.Error())
+		}
+
+		return bookmarkSavedMsg(fmt.Sprintf("Bookmarked %s as %s", directory, name))
+	}
+}
+
+// deleteBookmarkCmd removes the bookmark with the given name.
+func deleteBookmarkCmd(name string) tea.Cmd {
+	return func() tea.Msg {
+		store, err := bookmarks.Load()
+		if err != nil {
+			return errorMsg(err.Error())
+		}
+
+		store.Delete(name)
+
+		if err := store.Save(); err != nil {
+			return errorMsg(err.Error())
+		}
+
+		return bookmarksMsg(store.List())
+	}
+}
+
+// jumpToBookmarkCmd looks up the directory bookmarked with the given name.
+func jumpToBookmarkCmd(name string) tea.Cmd {
+	return func() tea.Msg {
+		store, err := bookmarks.Load()
+		if err != nil {
+			return errorMsg(err.Error())
+		}
+
+		directory, ok := store.Get(name)
+		if !ok {
+			return errorMsg(fmt.Sprintf("No bookmark named %s", name))
+		}
+
+		return jumpToDirectoryMsg(directory)
+	}
+}
+
+// recordVisitCmd records a visit to the directory in the frecency database.
+func recordVisitCmd(directory string) tea.Cmd {
+	return func() tea.Msg {
+		if err := frecency.Record(directory); err != nil {
+			return errorMsg(err.Error())
+		}
+
+		return nil
+	}
+}
+
+// jumpToFrecentCmd looks up the most frecent directory matching the query.
+func jumpToFrecentCmd(query, currentDirectory string) tea.Cmd {
+	return func() tea.Msg {
+		directory, err := frecency.Find(query, currentDirectory)
+		if err != nil {
+			return errorMsg(err.Error())
+		}
+
+		return jumpToDirectoryMsg(directory)
+	}
+}
+
 // newStatusMessage sets a new status message, which will show for a limited
 // amount of time.
 func (m *model) newStatusMessageCmd(s string) tea.Cmd {
@@ -81,3 +271,36 @@ func (m *model) newStatusMessageCmd(s string) tea.Cmd {
 		return statusMessageTimeoutMsg{}
 	}
 }
+
+// gitDiffCmd reads the changes to the item at path since the last commit.
+func gitDiffCmd(path string) tea.Cmd {
+	return func() tea.Msg {
+		diff, err := gitrepo.Diff(path)
+		if err != nil {
+			return errorMsg(err.Error())
+		}
+
+		return gitDiffMsg(diff)
+	}
+}
+
+// gitBlameCmd reads who last changed each line of the file at path.
+func gitBlameCmd(path string) tea.Cmd {
+	return func() tea.Msg {
+		blame, err := gitrepo.Blame(path)
+		if err != nil {
+			return errorMsg(err.Error())
+		}
+
+		return gitBlameMsg(blame)
+	}
+}
+
+// showGitOutputCmd shows the output of a git command in the preview pane,
+// highlighted with the lexer provided.
+func (m *model) showGitOutputCmd(content, lexer string) tea.Cmd {
+	m.resetViewports()
+	m.state = showCodeState
+
+	return m.code.SetContentCmd(content, lexer)
+}
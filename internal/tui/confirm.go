@@ -0,0 +1,308 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/exp/term/ansi"
+
+	"github.com/mistakenelf/fm/filesystem"
+	"github.com/mistakenelf/fm/filetree"
+)
+
+// Actions which can be configured to require confirmation.
+const (
+	ConfirmTrash     = "trash"
+	ConfirmDelete    = "delete"
+	ConfirmOverwrite = "overwrite"
+	ConfirmBulk      = "bulk"
+)
+
+// ConfirmActions are the actions which can require confirmation, all of which
+// do by default.
+var ConfirmActions = []string{ConfirmTrash, ConfirmDelete, ConfirmOverwrite, ConfirmBulk}
+
+// maxConfirmItems is the number of affected items listed by a confirmation.
+const maxConfirmItems = 5
+
+type confirmSizeMsg struct {
+	id   int
+	size int64
+}
+
+// confirmation is a modal asking whether to go ahead with an action, showing
+// the items it affects and their total size. Once confirmed, the key press
+// which started the action is handled again.
+type confirmation struct {
+	id        int
+	prompt    string
+	paths     []string
+	size      int64
+	sizeKnown bool
+	keyMsg    tea.KeyMsg
+	cancel    context.CancelFunc
+}
+
+// requiresConfirmation reports whether an action has to be confirmed.
+func (m model) requiresConfirmation(action string) bool {
+	return slices.Contains(m.config.Confirm, action)
+}
+
+// itemsDescription describes the items an action affects, naming a single
+// item and counting several.
+func itemsDescription(paths []string) string {
+	if len(paths) == 1 {
+		return filepath.Base(paths[0])
+	}
+
+	return fmt.Sprintf("%d items", len(paths))
+}
+
+// itemPaths returns the paths of the directory items provided.
+func itemPaths(items []filetree.DirectoryItem) []string {
+	paths := make([]string, 0, len(items))
+
+	for _, item := range items {
+		paths = append(paths, item.Path)
+	}
+
+	return paths
+}
+
+// existingPaths returns the paths provided which something is found at.
+func existingPaths(paths []string) []string {
+	var existing []string
+
+	for _, path := range paths {
+		if _, err := os.Lstat(path); err == nil {
+			existing = append(existing, path)
+		}
+	}
+
+	return existing
+}
+
+// confirmationPrompt returns the question to ask before handling a key press
+// and the paths it affects, or false when it can go ahead right away.
+func (m model) confirmationPrompt(msg tea.KeyMsg) (string, []string, bool) {
+	if m.activePane != 0 {
+		return "", nil, false
+	}
+
+	if m.filetree.State == filetree.IdleState && !m.showTextInput {
+		return m.idleConfirmationPrompt(msg)
+	}
+
+	if !key.Matches(msg, m.keyMap.Submit) {
+		return "", nil, false
+	}
+
+	switch m.filetree.State {
+	case filetree.CreateFileState:
+		existing := existingPaths([]string{m.textinput.Value()})
+		if len(existing) > 0 && m.requiresConfirmation(ConfirmOverwrite) {
+			return fmt.Sprintf("Replace %s with an empty file?", itemsDescription(existing)), existing, true
+		}
+	case filetree.MoveState:
+		items := m.filetree.GetOperationItems(m.filetree.GetSelectedItems())
+		if len(items) > 1 && m.requiresConfirmation(ConfirmBulk) {
+			paths := itemPaths(items)
+
+			return fmt.Sprintf("Move %s to %s?", itemsDescription(paths), m.secondaryFiletree.CurrentDirectory), paths, true
+		}
+	case filetree.CreateSymlinkState, filetree.CreateHardlinkState:
+		items := m.filetree.GetSelectedItems()
+		if len(items) > 1 && m.requiresConfirmation(ConfirmBulk) {
+			paths := itemPaths(items)
+
+			return fmt.Sprintf("Link to %s?", itemsDescription(paths)), paths, true
+		}
+	}
+
+	return "", nil, false
+}
+
+// idleConfirmationPrompt returns the question to ask before handling a key
+// press on the filetree, like confirmationPrompt.
+func (m model) idleConfirmationPrompt(msg tea.KeyMsg) (string, []string, bool) {
+	if key.Matches(msg, m.keyMap.Paste) {
+		return m.pasteConfirmationPrompt()
+	}
+
+	items := m.filetree.GetOperationItems(m.filetree.GetSelectedItems())
+	paths := itemPaths(items)
+
+	if len(items) == 0 {
+		return "", nil, false
+	}
+
+	bulk := len(items) > 1 && m.requiresConfirmation(ConfirmBulk)
+
+	switch {
+	case key.Matches(msg, m.keyMap.DeleteDirectoryItem):
+		if bulk || m.requiresConfirmation(ConfirmTrash) {
+			return fmt.Sprintf("Move %s to the trash?", itemsDescription(paths)), paths, true
+		}
+	case key.Matches(msg, m.keyMap.PermanentlyDelete):
+		if bulk || m.requiresConfirmation(ConfirmDelete) {
+			return fmt.Sprintf("Permanently delete %s? This can't be undone.", itemsDescription(paths)), paths, true
+		}
+	case key.Matches(msg, m.keyMap.GitDiscard):
+		if bulk || m.requiresConfirmation(ConfirmOverwrite) {
+			return fmt.Sprintf("Discard the unstaged changes to %s?", itemsDescription(paths)), paths, true
+		}
+	case key.Matches(msg, m.keyMap.UnzipDirectoryItem):
+		if bulk {
+			return fmt.Sprintf("Unzip %s?", itemsDescription(paths)), paths, true
+		}
+	case key.Matches(msg, m.keyMap.CopyDirectoryItem):
+		if bulk {
+			return fmt.Sprintf("Copy %s?", itemsDescription(paths)), paths, true
+		}
+	case key.Matches(msg, m.keyMap.ZipDirectoryItem):
+		if bulk {
+			return fmt.Sprintf("Zip %s?", itemsDescription(paths)), paths, true
+		}
+	case key.Matches(msg, m.keyMap.GitStage), key.Matches(msg, m.keyMap.GitUnstage):
+		if bulk {
+			return fmt.Sprintf("Change the staged state of %s?", itemsDescription(paths)), paths, true
+		}
+	}
+
+	return "", nil, false
+}
+
+// pasteConfirmationPrompt returns the question to ask before pasting the
+// active register, like confirmationPrompt.
+func (m model) pasteConfirmationPrompt() (string, []string, bool) {
+	content, _ := m.activeRegisterContent()
+
+	if len(content.paths) > 1 && m.requiresConfirmation(ConfirmBulk) {
+		return fmt.Sprintf("Paste %s?", itemsDescription(content.paths)), content.paths, true
+	}
+
+	return "", nil, false
+}
+
+// confirmCmd opens a confirmation for a key press, calculating the total size
+// of the items it affects in the background.
+func (m *model) confirmCmd(msg tea.KeyMsg, prompt string, paths []string) tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m.confirmationID++
+	m.confirmation = &confirmation{
+		id:     m.confirmationID,
+		prompt: prompt,
+		paths:  paths,
+		keyMsg: msg,
+		cancel: cancel,
+	}
+
+	id := m.confirmationID
+
+	return func() tea.Msg {
+		var total int64
+
+		for _, path := range paths {
+			size, err := filesystem.GetDirectoryItemSizeContext(ctx, path)
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			if err == nil {
+				total += size
+			}
+		}
+
+		return confirmSizeMsg{id: id, size: total}
+	}
+}
+
+// closeConfirmation closes the confirmation, stopping the calculation of the
+// size of its items.
+func (m *model) closeConfirmation() {
+	if m.confirmation != nil {
+		m.confirmation.cancel()
+	}
+
+	m.confirmation = nil
+}
+
+// updateConfirmation handles key presses while a confirmation is open. The
+// action is only taken once it is confirmed, anything else cancels it.
+func (m model) updateConfirmation(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if key.Matches(msg, m.keyMap.ForceQuit) {
+		return m, tea.Quit
+	}
+
+	keyMsg := m.confirmation.keyMsg
+	m.closeConfirmation()
+
+	if !key.Matches(msg, m.keyMap.Confirm) {
+		m.activeRegister = unnamedRegister
+
+		return m, nil
+	}
+
+	m.confirmed = true
+	updated, cmd := m.Update(keyMsg)
+
+	next := updated.(model)
+	next.confirmed = false
+
+	return next, cmd
+}
+
+// confirmationView renders the confirmation in the middle of the preview pane.
+func (m model) confirmationView(width, height int) string {
+	c := m.confirmation
+	boxWidth := min(max(width-4, 0), 60)
+
+	var body strings.Builder
+
+	body.WriteString(lipgloss.NewStyle().Bold(true).Render(c.prompt))
+	body.WriteString("\n\n")
+
+	for i, path := range c.paths {
+		if i == maxConfirmItems {
+			fmt.Fprintf(&body, "and %d more\n", len(c.paths)-maxConfirmItems)
+
+			break
+		}
+
+		body.WriteString(ansi.Truncate(path, boxWidth-4, "…") + "\n")
+	}
+
+	size := "calculating size…"
+	if c.sizeKnown {
+		size = filesystem.ConvertBytesToSizeString(c.size)
+	}
+
+	count := fmt.Sprintf("%d items", len(c.paths))
+	if len(c.paths) == 1 {
+		count = "1 item"
+	}
+
+	fmt.Fprintf(&body, "\n%s, %s\n\n", count, size)
+	body.WriteString(
+		lipgloss.NewStyle().
+			Foreground(m.config.Theme.SelectedTreeItemColor).
+			Render(fmt.Sprintf("%s confirm • any other key cancels", m.keyMap.Confirm.Help().Key)),
+	)
+
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(m.config.Theme.SelectedTreeItemColor).
+		Padding(0, 1).
+		Width(boxWidth).
+		Render(body.String())
+
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, box)
+}
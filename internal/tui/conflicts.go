@@ -0,0 +1,273 @@
+package tui
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/exp/term/ansi"
+
+	"github.com/mistakenelf/fm/filesystem"
+	"github.com/mistakenelf/fm/filetree"
+)
+
+// conflictPrompt asks how each item whose destination already exists is
+// handled before a copy, move, rename or extraction. Once every conflict is
+// resolved, the key press which started it is handled again.
+type conflictPrompt struct {
+	keyMsg      tea.KeyMsg
+	conflicts   []filesystem.Conflict
+	resolutions map[string]filesystem.ConflictResolution
+	index       int
+	applyToAll  bool
+}
+
+// current returns the conflict being asked about.
+func (c conflictPrompt) current() filesystem.Conflict {
+	return c.conflicts[c.index]
+}
+
+// findConflicts returns the conflicts handling a key press runs into, each
+// destination once since it is resolved once.
+func (m model) findConflicts(msg tea.KeyMsg) ([]filesystem.Conflict, error) {
+	keyConflicts, err := m.keyConflicts(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []filesystem.Conflict
+
+	seen := make(map[string]bool)
+
+	for _, conflict := range keyConflicts {
+		if !seen[conflict.Destination] {
+			seen[conflict.Destination] = true
+			conflicts = append(conflicts, conflict)
+		}
+	}
+
+	return conflicts, nil
+}
+
+// keyConflicts returns the conflicts handling a key press runs into.
+func (m model) keyConflicts(msg tea.KeyMsg) ([]filesystem.Conflict, error) {
+	if m.activePane != 0 {
+		return nil, nil
+	}
+
+	if m.filetree.State == filetree.IdleState && !m.showTextInput {
+		switch {
+		case key.Matches(msg, m.keyMap.Paste):
+			content, _ := m.activeRegisterContent()
+
+			// Pasting fails before touching anything when the destinations
+			// collide, so there is nothing to ask about.
+			destinations, err := m.filetree.PasteDestinations(content.paths, content.cut)
+			if err != nil {
+				return nil, nil
+			}
+
+			var conflicts []filesystem.Conflict
+
+			for i, path := range content.paths {
+				if destinations[i] == "" {
+					continue
+				}
+
+				if conflict, ok := filesystem.FindConflict(path, destinations[i]); ok {
+					conflicts = append(conflicts, conflict)
+				}
+			}
+
+			return conflicts, nil
+		case key.Matches(msg, m.keyMap.UnzipDirectoryItem):
+			var conflicts []filesystem.Conflict
+
+			for _, item := range m.filetree.GetSelectedItems() {
+				archiveConflicts, err := filesystem.UnzipConflicts(item.Path)
+				if err != nil {
+					return nil, err
+				}
+
+				conflicts = append(conflicts, archiveConflicts...)
+			}
+
+			return conflicts, nil
+		}
+
+		return nil, nil
+	}
+
+	if !key.Matches(msg, m.keyMap.Submit) {
+		return nil, nil
+	}
+
+	switch m.filetree.State {
+	case filetree.RenameState:
+		item := m.filetree.GetOperationItems([]filetree.DirectoryItem{m.filetree.GetSelectedItem()})[0]
+
+		return findConflicts([]string{item.Path}, func(string) string {
+			return filepath.Join(filepath.Dir(item.Path), m.textinput.Value())
+		}), nil
+	case filetree.MoveState:
+		paths := itemPaths(m.filetree.GetOperationItems(m.filetree.GetSelectedItems()))
+
+		return findConflicts(paths, func(path string) string {
+			return filepath.Join(m.secondaryFiletree.CurrentDirectory, filepath.Base(path))
+		}), nil
+	}
+
+	return nil, nil
+}
+
+// findConflicts returns the conflicts of moving or copying the items at the
+// paths provided to their destinations. Items which stay where they are
+// don't conflict.
+func findConflicts(paths []string, destination func(path string) string) []filesystem.Conflict {
+	var conflicts []filesystem.Conflict
+
+	for _, path := range paths {
+		dst := destination(path)
+		if dst == path {
+			continue
+		}
+
+		if conflict, ok := filesystem.FindConflict(path, dst); ok {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+
+	return conflicts
+}
+
+// conflictResolver returns the resolver of the conflicts resolved by the
+// last conflict prompt. Conflicts it didn't ask about are left unresolved.
+func (m model) conflictResolver() filetree.ConflictResolver {
+	resolutions := m.conflictResolutions
+
+	return func(dst string) filesystem.ConflictResolution {
+		return resolutions[dst]
+	}
+}
+
+// updateConflictPrompt handles key presses while the conflict prompt is open.
+// Choosing how to resolve a conflict moves on to the next one, or to all of
+// them when applied to all.
+func (m model) updateConflictPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var resolution filesystem.ConflictResolution
+
+	switch {
+	case key.Matches(msg, m.keyMap.ForceQuit):
+		return m, tea.Quit
+	case key.Matches(msg, m.keyMap.ResetState):
+		m.conflictPrompt = nil
+		m.activeRegister = unnamedRegister
+
+		return m, nil
+	case key.Matches(msg, m.keyMap.ApplyToAll):
+		prompt := *m.conflictPrompt
+		prompt.applyToAll = !prompt.applyToAll
+		m.conflictPrompt = &prompt
+
+		return m, nil
+	case key.Matches(msg, m.keyMap.Overwrite):
+		resolution = filesystem.ConflictOverwrite
+	case key.Matches(msg, m.keyMap.Skip):
+		resolution = filesystem.ConflictSkip
+	case key.Matches(msg, m.keyMap.KeepBoth):
+		resolution = filesystem.ConflictKeepBoth
+	case key.Matches(msg, m.keyMap.OverwriteIfNewer):
+		resolution = filesystem.ConflictOverwriteIfNewer
+	default:
+		return m, nil
+	}
+
+	prompt := *m.conflictPrompt
+	prompt.resolutions[prompt.current().Destination] = resolution
+	prompt.index++
+
+	for prompt.applyToAll && prompt.index < len(prompt.conflicts) {
+		prompt.resolutions[prompt.current().Destination] = resolution
+		prompt.index++
+	}
+
+	if prompt.index < len(prompt.conflicts) {
+		m.conflictPrompt = &prompt
+
+		return m, nil
+	}
+
+	m.conflictPrompt = nil
+	m.confirmed = true
+	m.conflictResolutions = prompt.resolutions
+	updated, cmd := m.Update(prompt.keyMsg)
+
+	next := updated.(model)
+	next.confirmed = false
+	next.conflictResolutions = nil
+
+	return next, cmd
+}
+
+// fileDescription describes the size and modification time of an item.
+func fileDescription(info fs.FileInfo) string {
+	size := "directory"
+	if !info.IsDir() {
+		size = filesystem.ConvertBytesToSizeString(info.Size())
+	}
+
+	return fmt.Sprintf("%s, modified %s", size, info.ModTime().Format("2006-01-02 15:04:05"))
+}
+
+// conflictPromptView renders the conflict prompt in the middle of the preview
+// pane.
+func (m model) conflictPromptView(width, height int) string {
+	c := m.conflictPrompt
+	conflict := c.current()
+	boxWidth := min(max(width-4, 0), 60)
+
+	var body strings.Builder
+
+	body.WriteString(lipgloss.NewStyle().Bold(true).Render(
+		fmt.Sprintf("%s already exists", filepath.Base(conflict.Destination)),
+	))
+	body.WriteString("\n\n")
+	body.WriteString(ansi.Truncate(conflict.Destination, boxWidth-4, "…") + "\n\n")
+	fmt.Fprintf(&body, "Existing: %s\n", fileDescription(conflict.Existing))
+	fmt.Fprintf(&body, "Incoming: %s\n\n", fileDescription(conflict.Source))
+	fmt.Fprintf(&body, "Conflict %d of %d\n\n", c.index+1, len(c.conflicts))
+
+	applyToAll := "[ ]"
+	if c.applyToAll {
+		applyToAll = "[x]"
+	}
+
+	choices := []string{
+		fmt.Sprintf("%s overwrite", m.keyMap.Overwrite.Help().Key),
+		fmt.Sprintf("%s skip", m.keyMap.Skip.Help().Key),
+		fmt.Sprintf("%s keep both", m.keyMap.KeepBoth.Help().Key),
+		fmt.Sprintf("%s overwrite if newer", m.keyMap.OverwriteIfNewer.Help().Key),
+		fmt.Sprintf("%s %s apply to all", m.keyMap.ApplyToAll.Help().Key, applyToAll),
+		fmt.Sprintf("%s cancel", m.keyMap.ResetState.Help().Key),
+	}
+
+	body.WriteString(
+		lipgloss.NewStyle().
+			Foreground(m.config.Theme.SelectedTreeItemColor).
+			Width(boxWidth - 2).
+			Render(strings.Join(choices, " • ")),
+	)
+
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(m.config.Theme.SelectedTreeItemColor).
+		Padding(0, 1).
+		Width(boxWidth).
+		Render(body.String())
+
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, box)
+}
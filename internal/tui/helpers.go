@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	"github.com/charmbracelet/lipgloss"
+
+	"github.com/mistakenelf/fm/filetree"
 	"github.com/mistakenelf/fm/polish"
 )
 
@@ -35,6 +37,25 @@ func (m *model) resetViewports() {
 	m.csv.GotoTop()
 }
 
+// getTextInputPrompt returns the text input along with a prompt describing
+// what it is for.
+func (m model) getTextInputPrompt() string {
+	switch m.filetree.State {
+	case filetree.FilterState:
+		return fmt.Sprintf("filter (%s) %s", m.filetree.GetFilterMode(), m.textinput.View())
+	case filetree.AddBookmarkState:
+		return "bookmark as " + m.textinput.View()
+	case filetree.JumpState:
+		return "jump to " + m.textinput.View()
+	case filetree.CreateSymlinkState:
+		return "symlink at " + m.textinput.View()
+	case filetree.CreateHardlinkState:
+		return "hard link at " + m.textinput.View()
+	default:
+		return m.textinput.View()
+	}
+}
+
 func (m *model) updateStatusBar() {
 	if m.filetree.GetSelectedItem().Name != "" {
 		statusMessage :=
@@ -68,21 +89,50 @@ func (m *model) updateStatusBar() {
 			statusMessage = m.statusMessage
 		}
 
+		if m.filetree.GetFilter() != "" {
+			statusMessage += lipgloss.NewStyle().
+				Padding(0, 1).
+				Foreground(polish.Colors.Yellow500).
+				Render(fmt.Sprintf("[%s: %s]", m.filetree.GetFilterMode(), m.filetree.GetFilter()))
+		}
+
 		if m.showTextInput {
-			statusMessage = m.textinput.View()
+			statusMessage = m.getTextInputPrompt()
+		}
+
+		totalItems := fmt.Sprintf("%s | %d/%d", m.filetree.GetSortDescription(), m.filetree.Cursor+1, m.filetree.GetTotalItems())
+
+		if m.filetree.GetTotalMarked() > 0 {
+			totalItems = fmt.Sprintf("%d marked | %s", m.filetree.GetTotalMarked(), totalItems)
+		}
+
+		if m.filetree.IsLoading() {
+			totalItems = "loading… | " + totalItems
+		}
+
+		if branch, ok := m.filetree.GetGitBranch(); ok {
+			totalItems = branch.String() + " | " + totalItems
 		}
 
 		m.statusbar.SetContent(
 			m.filetree.GetSelectedItem().Name,
 			statusMessage,
-			fmt.Sprintf("%d/%d", m.filetree.Cursor+1, m.filetree.GetTotalItems()),
-			fmt.Sprintf(m.filetree.GetSelectedItem().FileSize),
+			totalItems,
+			m.filetree.GetSelectedItemSize(),
 		)
 	} else {
 		statusMessage := "Directory is empty"
 
+		if m.filetree.IsLoading() {
+			statusMessage = "Loading directory…"
+		}
+
+		if m.filetree.GetFilter() != "" {
+			statusMessage = fmt.Sprintf("No items match %s filter %q", m.filetree.GetFilterMode(), m.filetree.GetFilter())
+		}
+
 		if m.showTextInput {
-			statusMessage = m.textinput.View()
+			statusMessage = m.getTextInputPrompt()
 		}
 
 		m.statusbar.SetContent(
@@ -7,13 +7,18 @@ import (
 
 	"github.com/mistakenelf/fm/code"
 	"github.com/mistakenelf/fm/csv"
+	"github.com/mistakenelf/fm/filesystem"
 	"github.com/mistakenelf/fm/filetree"
+	"github.com/mistakenelf/fm/finder"
 	"github.com/mistakenelf/fm/help"
 	"github.com/mistakenelf/fm/image"
+	"github.com/mistakenelf/fm/internal/journal"
 	"github.com/mistakenelf/fm/internal/theme"
+	"github.com/mistakenelf/fm/internal/views"
 	"github.com/mistakenelf/fm/keys"
 	"github.com/mistakenelf/fm/markdown"
 	"github.com/mistakenelf/fm/pdf"
+	"github.com/mistakenelf/fm/picker"
 	"github.com/mistakenelf/fm/statusbar"
 )
 
@@ -28,8 +33,31 @@ const (
 	showHelpState
 	showMoveState
 	showCsvState
+	showFinderState
+	showDirectoryPreviewState
+	showHistoryState
+	showBookmarksState
+	showTrashState
 )
 
+// markAction is the action waiting for the letter of a mark.
+type markAction int
+
+const (
+	noMarkAction markAction = iota
+	setMarkAction
+	jumpToMarkAction
+)
+
+// Available layouts.
+const (
+	DefaultLayout = "default"
+	MillerLayout  = "miller"
+)
+
+// Layouts are the available layouts.
+var Layouts = []string{DefaultLayout, MillerLayout}
+
 type Config struct {
 	StartDir       string
 	SelectionPath  string
@@ -37,13 +65,33 @@ type Config struct {
 	EnableLogging  bool
 	PrettyMarkdown bool
 	ShowIcons      bool
+	Layout         string
+	HidePatterns   []string
+	Views          *views.Store
+	Journal        *journal.Journal
+	Confirm        []string
 	Theme          theme.Theme
 }
 
 type model struct {
 	filetree              filetree.Model
 	secondaryFiletree     filetree.Model
+	parentFiletree        filetree.Model
+	previewFiletree       filetree.Model
 	csv                   csv.Model
+	finder                finder.Model
+	historyPicker         picker.Model
+	bookmarksPicker       picker.Model
+	trashPicker           picker.Model
+	pendingMark           markAction
+	confirmation          *confirmation
+	confirmationID        int
+	confirmed             bool
+	conflictPrompt        *conflictPrompt
+	conflictResolutions   map[string]filesystem.ConflictResolution
+	registers             map[rune]register
+	activeRegister        rune
+	pendingRegister       bool
 	help                  help.Model
 	code                  code.Model
 	image                 image.Model
@@ -58,22 +106,47 @@ type model struct {
 	textinput             textinput.Model
 	statusMessage         string
 	directoryBeforeMove   string
+	parentDirectory       string
+	previewPath           string
+	visitedDirectory      string
+	previewItem           filetree.DirectoryItem
+	tabs                  []tab
+	activeTab             int
+	width                 int
+	height                int
+	previewWidth          int
+	paneHeight            int
 	statusMessageLifetime time.Duration
 	statusMessageTimer    *time.Timer
 }
 
 // New creates a new instance of the UI.
 func New(cfg Config) model {
-	filetreeModel := filetree.New(cfg.StartDir)
-	filetreeModel.SetTheme(cfg.Theme.SelectedTreeItemColor, cfg.Theme.UnselectedTreeItemColor)
-	filetreeModel.SetSelectionPath(cfg.SelectionPath)
-	filetreeModel.SetShowIcons(cfg.ShowIcons)
+	filetreeModel := newFiletree(cfg, cfg.StartDir)
 
 	secondaryFiletree := filetree.New(cfg.StartDir)
 	secondaryFiletree.SetTheme(cfg.Theme.SelectedTreeItemColor, cfg.Theme.UnselectedTreeItemColor)
 	secondaryFiletree.SetSelectionPath(cfg.SelectionPath)
 	secondaryFiletree.SetShowIcons(cfg.ShowIcons)
+	secondaryFiletree.SetHidePatterns(cfg.HidePatterns)
 	secondaryFiletree.SetDisabled(true)
+	secondaryFiletree.SetCalculateSizes(false)
+	secondaryFiletree.SetShowGitStatus(false)
+
+	parentFiletree := filetree.New(cfg.StartDir)
+	parentFiletree.SetTheme(cfg.Theme.SelectedTreeItemColor, cfg.Theme.UnselectedTreeItemColor)
+	parentFiletree.SetShowIcons(cfg.ShowIcons)
+	parentFiletree.SetHidePatterns(cfg.HidePatterns)
+	parentFiletree.SetCalculateSizes(false)
+	parentFiletree.SetShowGitStatus(false)
+
+	previewFiletree := filetree.New(cfg.StartDir)
+	previewFiletree.SetTheme(cfg.Theme.SelectedTreeItemColor, cfg.Theme.UnselectedTreeItemColor)
+	previewFiletree.SetShowIcons(cfg.ShowIcons)
+	previewFiletree.SetHidePatterns(cfg.HidePatterns)
+	previewFiletree.SetDisabled(true)
+	previewFiletree.SetCalculateSizes(false)
+	previewFiletree.SetShowGitStatus(false)
 
 	codeModel := code.New()
 	codeModel.SetSyntaxTheme(cfg.SyntaxTheme)
@@ -133,11 +206,14 @@ func New(cfg Config) model {
 			{Key: defaultKeyMap.GoToHomeDirectory.Help().Key, Description: defaultKeyMap.GoToHomeDirectory.Help().Desc},
 			{Key: defaultKeyMap.GoToRootDirectory.Help().Key, Description: defaultKeyMap.GoToRootDirectory.Help().Desc},
 			{Key: defaultKeyMap.ToggleHidden.Help().Key, Description: defaultKeyMap.ToggleHidden.Help().Desc},
+			{Key: defaultKeyMap.ToggleIgnored.Help().Key, Description: defaultKeyMap.ToggleIgnored.Help().Desc},
 			{Key: defaultKeyMap.OpenDirectory.Help().Key, Description: defaultKeyMap.OpenDirectory.Help().Desc},
 			{Key: defaultKeyMap.PreviousDirectory.Help().Key, Description: defaultKeyMap.PreviousDirectory.Help().Desc},
 			{Key: defaultKeyMap.CopyPathToClipboard.Help().Key, Description: defaultKeyMap.CopyPathToClipboard.Help().Desc},
 			{Key: defaultKeyMap.CopyDirectoryItem.Help().Key, Description: defaultKeyMap.CopyDirectoryItem.Help().Desc},
 			{Key: defaultKeyMap.DeleteDirectoryItem.Help().Key, Description: defaultKeyMap.DeleteDirectoryItem.Help().Desc},
+			{Key: defaultKeyMap.PermanentlyDelete.Help().Key, Description: defaultKeyMap.PermanentlyDelete.Help().Desc},
+			{Key: defaultKeyMap.ShowTrash.Help().Key, Description: defaultKeyMap.ShowTrash.Help().Desc},
 			{Key: defaultKeyMap.ZipDirectoryItem.Help().Key, Description: defaultKeyMap.ZipDirectoryItem.Help().Desc},
 			{Key: defaultKeyMap.UnzipDirectoryItem.Help().Key, Description: defaultKeyMap.UnzipDirectoryItem.Help().Desc},
 			{Key: defaultKeyMap.ShowDirectoriesOnly.Help().Key, Description: defaultKeyMap.ShowDirectoriesOnly.Help().Desc},
@@ -146,13 +222,104 @@ func New(cfg Config) model {
 			{Key: defaultKeyMap.OpenInEditor.Help().Key, Description: defaultKeyMap.OpenInEditor.Help().Desc},
 			{Key: defaultKeyMap.CreateFile.Help().Key, Description: defaultKeyMap.CreateFile.Help().Desc},
 			{Key: defaultKeyMap.CreateDirectory.Help().Key, Description: defaultKeyMap.CreateDirectory.Help().Desc},
+			{Key: defaultKeyMap.ToggleMark.Help().Key, Description: defaultKeyMap.ToggleMark.Help().Desc},
+			{Key: defaultKeyMap.MarkAll.Help().Key, Description: defaultKeyMap.MarkAll.Help().Desc},
+			{Key: defaultKeyMap.InvertMarks.Help().Key, Description: defaultKeyMap.InvertMarks.Help().Desc},
+			{Key: defaultKeyMap.ClearMarks.Help().Key, Description: defaultKeyMap.ClearMarks.Help().Desc},
+			{Key: defaultKeyMap.MarkByGlob.Help().Key, Description: defaultKeyMap.MarkByGlob.Help().Desc},
+			{Key: defaultKeyMap.FuzzyFind.Help().Key, Description: defaultKeyMap.FuzzyFind.Help().Desc},
+			{Key: defaultKeyMap.Filter.Help().Key, Description: defaultKeyMap.Filter.Help().Desc},
+			{Key: defaultKeyMap.CycleFilterMode.Help().Key, Description: defaultKeyMap.CycleFilterMode.Help().Desc},
+			{Key: defaultKeyMap.CycleSortOrder.Help().Key, Description: defaultKeyMap.CycleSortOrder.Help().Desc},
+			{Key: defaultKeyMap.ReverseSort.Help().Key, Description: defaultKeyMap.ReverseSort.Help().Desc},
+			{Key: defaultKeyMap.DirectoriesFirst.Help().Key, Description: defaultKeyMap.DirectoriesFirst.Help().Desc},
+			{Key: defaultKeyMap.ToggleDetails.Help().Key, Description: defaultKeyMap.ToggleDetails.Help().Desc},
+			{Key: defaultKeyMap.ToggleTreeView.Help().Key, Description: defaultKeyMap.ToggleTreeView.Help().Desc},
+			{Key: defaultKeyMap.ToggleExpand.Help().Key, Description: defaultKeyMap.ToggleExpand.Help().Desc},
+			{Key: defaultKeyMap.ExpandAll.Help().Key, Description: defaultKeyMap.ExpandAll.Help().Desc},
+			{Key: defaultKeyMap.CollapseAll.Help().Key, Description: defaultKeyMap.CollapseAll.Help().Desc},
+			{Key: defaultKeyMap.GoBack.Help().Key, Description: defaultKeyMap.GoBack.Help().Desc},
+			{Key: defaultKeyMap.GoForward.Help().Key, Description: defaultKeyMap.GoForward.Help().Desc},
+			{Key: defaultKeyMap.ShowHistory.Help().Key, Description: defaultKeyMap.ShowHistory.Help().Desc},
+			{Key: defaultKeyMap.AddBookmark.Help().Key, Description: defaultKeyMap.AddBookmark.Help().Desc},
+			{Key: defaultKeyMap.ShowBookmarks.Help().Key, Description: defaultKeyMap.ShowBookmarks.Help().Desc},
+			{Key: defaultKeyMap.DeleteBookmark.Help().Key, Description: defaultKeyMap.DeleteBookmark.Help().Desc},
+			{Key: defaultKeyMap.SetMark.Help().Key, Description: defaultKeyMap.SetMark.Help().Desc},
+			{Key: defaultKeyMap.JumpToMark.Help().Key, Description: defaultKeyMap.JumpToMark.Help().Desc},
+			{Key: defaultKeyMap.JumpToFrecent.Help().Key, Description: defaultKeyMap.JumpToFrecent.Help().Desc},
+			{Key: defaultKeyMap.NewTab.Help().Key, Description: defaultKeyMap.NewTab.Help().Desc},
+			{Key: defaultKeyMap.CloseTab.Help().Key, Description: defaultKeyMap.CloseTab.Help().Desc},
+			{Key: defaultKeyMap.NextTab.Help().Key, Description: defaultKeyMap.NextTab.Help().Desc},
+			{Key: defaultKeyMap.PreviousTab.Help().Key, Description: defaultKeyMap.PreviousTab.Help().Desc},
+			{Key: defaultKeyMap.JumpToTab.Help().Key, Description: defaultKeyMap.JumpToTab.Help().Desc},
+			{Key: defaultKeyMap.GitStage.Help().Key, Description: defaultKeyMap.GitStage.Help().Desc},
+			{Key: defaultKeyMap.GitUnstage.Help().Key, Description: defaultKeyMap.GitUnstage.Help().Desc},
+			{Key: defaultKeyMap.GitDiscard.Help().Key, Description: defaultKeyMap.GitDiscard.Help().Desc},
+			{Key: defaultKeyMap.GitDiff.Help().Key, Description: defaultKeyMap.GitDiff.Help().Desc},
+			{Key: defaultKeyMap.GitBlame.Help().Key, Description: defaultKeyMap.GitBlame.Help().Desc},
+			{Key: defaultKeyMap.CreateSymlink.Help().Key, Description: defaultKeyMap.CreateSymlink.Help().Desc},
+			{Key: defaultKeyMap.CreateHardlink.Help().Key, Description: defaultKeyMap.CreateHardlink.Help().Desc},
+			{Key: defaultKeyMap.ToggleFollowLinks.Help().Key, Description: defaultKeyMap.ToggleFollowLinks.Help().Desc},
+			{Key: defaultKeyMap.Undo.Help().Key, Description: defaultKeyMap.Undo.Help().Desc},
+			{Key: defaultKeyMap.Redo.Help().Key, Description: defaultKeyMap.Redo.Help().Desc},
+			{Key: defaultKeyMap.Confirm.Help().Key, Description: defaultKeyMap.Confirm.Help().Desc},
+			{Key: defaultKeyMap.Yank.Help().Key, Description: defaultKeyMap.Yank.Help().Desc},
+			{Key: defaultKeyMap.Cut.Help().Key, Description: defaultKeyMap.Cut.Help().Desc},
+			{Key: defaultKeyMap.Paste.Help().Key, Description: defaultKeyMap.Paste.Help().Desc},
+			{Key: defaultKeyMap.SelectRegister.Help().Key, Description: defaultKeyMap.SelectRegister.Help().Desc},
+			{Key: defaultKeyMap.Overwrite.Help().Key, Description: defaultKeyMap.Overwrite.Help().Desc},
+			{Key: defaultKeyMap.Skip.Help().Key, Description: defaultKeyMap.Skip.Help().Desc},
+			{Key: defaultKeyMap.KeepBoth.Help().Key, Description: defaultKeyMap.KeepBoth.Help().Desc},
+			{Key: defaultKeyMap.OverwriteIfNewer.Help().Key, Description: defaultKeyMap.OverwriteIfNewer.Help().Desc},
+			{Key: defaultKeyMap.ApplyToAll.Help().Key, Description: defaultKeyMap.ApplyToAll.Help().Desc},
 		},
 	)
 	helpModel.SetViewportDisabled(true)
 
+	finderModel := finder.New(
+		"Find",
+		finder.TitleColor{
+			Background: cfg.Theme.TitleBackgroundColor,
+			Foreground: cfg.Theme.TitleForegroundColor,
+		},
+	)
+	finderModel.SetTheme(cfg.Theme.SelectedTreeItemColor, cfg.Theme.UnselectedTreeItemColor)
+
+	historyPicker := picker.New(
+		"History",
+		picker.TitleColor{
+			Background: cfg.Theme.TitleBackgroundColor,
+			Foreground: cfg.Theme.TitleForegroundColor,
+		},
+	)
+	historyPicker.SetTheme(cfg.Theme.SelectedTreeItemColor, cfg.Theme.UnselectedTreeItemColor)
+	historyPicker.EmptyMessage = "No directories visited yet"
+
+	bookmarksPicker := picker.New(
+		"Bookmarks",
+		picker.TitleColor{
+			Background: cfg.Theme.TitleBackgroundColor,
+			Foreground: cfg.Theme.TitleForegroundColor,
+		},
+	)
+	bookmarksPicker.SetTheme(cfg.Theme.SelectedTreeItemColor, cfg.Theme.UnselectedTreeItemColor)
+	bookmarksPicker.EmptyMessage = "No bookmarks yet, press B to bookmark a directory"
+
+	trashPicker := picker.New(
+		"Trash",
+		picker.TitleColor{
+			Background: cfg.Theme.TitleBackgroundColor,
+			Foreground: cfg.Theme.TitleForegroundColor,
+		},
+	)
+	trashPicker.SetTheme(cfg.Theme.SelectedTreeItemColor, cfg.Theme.UnselectedTreeItemColor)
+	trashPicker.EmptyMessage = "The trash is empty"
+
 	return model{
 		filetree:              filetreeModel,
 		secondaryFiletree:     secondaryFiletree,
+		parentFiletree:        parentFiletree,
+		previewFiletree:       previewFiletree,
 		help:                  helpModel,
 		code:                  codeModel,
 		image:                 imageModel,
@@ -165,5 +332,12 @@ func New(cfg Config) model {
 		textinput:             textinput.New(),
 		statusMessageLifetime: time.Second,
 		csv:                   csv.New(),
+		finder:                finderModel,
+		historyPicker:         historyPicker,
+		tabs:                  make([]tab, 1),
+		bookmarksPicker:       bookmarksPicker,
+		trashPicker:           trashPicker,
+		registers:             make(map[rune]register),
+		activeRegister:        unnamedRegister,
 	}
 }
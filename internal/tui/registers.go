@@ -0,0 +1,119 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// unnamedRegister is the register used unless another is selected.
+const unnamedRegister = '"'
+
+// register holds the paths of the items yanked or cut into it.
+type register struct {
+	paths []string
+	cut   bool
+}
+
+// registerName returns how a register is referred to in messages.
+func registerName(name rune) string {
+	if name == unnamedRegister {
+		return "the unnamed register"
+	}
+
+	return fmt.Sprintf("register %c", name)
+}
+
+// updatePendingRegister selects the register named by the key pressed after
+// the select register key for the next yank, cut or paste. Registers are
+// named by letters, with uppercase letters adding to the lowercase register.
+// Any other key cancels it.
+func (m model) updatePendingRegister(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.pendingRegister = false
+
+	if msg.Type != tea.KeyRunes || len(msg.Runes) != 1 {
+		return m, nil
+	}
+
+	name := msg.Runes[0]
+	if name != unnamedRegister && (name > unicode.MaxASCII || !unicode.IsLetter(name)) {
+		return m, nil
+	}
+
+	m.activeRegister = name
+
+	return m, nil
+}
+
+// storeRegisterCmd yanks or cuts the selected or marked items into the
+// active register.
+func (m *model) storeRegisterCmd(cut bool) tea.Cmd {
+	paths := itemPaths(m.filetree.GetOperationItems(m.filetree.GetSelectedItems()))
+	name := m.activeRegister
+	m.activeRegister = unnamedRegister
+
+	if len(paths) == 0 {
+		return nil
+	}
+
+	// Adding to a register keeps what is in it, unless it was cut and these
+	// are yanked or the other way around.
+	if unicode.IsUpper(name) {
+		name = unicode.ToLower(name)
+
+		if existing, ok := m.registers[name]; ok && existing.cut == cut {
+			added := paths
+			paths = append([]string{}, existing.paths...)
+
+			for _, path := range added {
+				if !slices.Contains(paths, path) {
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+
+	m.registers[name] = register{paths: paths, cut: cut}
+	m.filetree.ClearMarks()
+
+	done := "Yanked"
+	if cut {
+		done = "Cut"
+	}
+
+	return m.newStatusMessageCmd(fmt.Sprintf("%s %s into %s", done, itemsDescription(paths), registerName(name)))
+}
+
+// activeRegisterContent returns the register items are pasted from.
+func (m model) activeRegisterContent() (register, rune) {
+	name := unicode.ToLower(m.activeRegister)
+
+	return m.registers[name], name
+}
+
+// pasteCmd pastes the items of the active register into the current
+// directory. Items which were cut are moved, after which the register is
+// emptied since they are no longer where they were cut from. Registers which
+// can't be pasted here are kept.
+func (m *model) pasteCmd() tea.Cmd {
+	content, name := m.activeRegisterContent()
+	m.activeRegister = unnamedRegister
+
+	if len(content.paths) == 0 {
+		return m.newStatusMessageCmd(fmt.Sprintf("Nothing in %s to paste", registerName(name)))
+	}
+
+	if _, err := m.filetree.PasteDestinations(content.paths, content.cut); err != nil {
+		return func() tea.Msg {
+			return errorMsg(err.Error())
+		}
+	}
+
+	if content.cut {
+		delete(m.registers, name)
+	}
+
+	return m.filetree.PasteCmd(content.paths, content.cut, m.conflictResolver())
+}
@@ -0,0 +1,195 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/mistakenelf/fm/filetree"
+	"github.com/mistakenelf/fm/statusbar"
+)
+
+const tabBarHeight = 1
+
+// tab holds the state of a tab while another tab is active.
+type tab struct {
+	filetree    filetree.Model
+	state       sessionState
+	previewItem filetree.DirectoryItem
+}
+
+// newFiletree creates a filetree configured for a tab starting in the
+// directory provided.
+func newFiletree(cfg Config, startDir string) filetree.Model {
+	filetreeModel := filetree.New(startDir)
+	filetreeModel.SetTheme(cfg.Theme.SelectedTreeItemColor, cfg.Theme.UnselectedTreeItemColor)
+	filetreeModel.SetSelectionPath(cfg.SelectionPath)
+	filetreeModel.SetShowIcons(cfg.ShowIcons)
+	filetreeModel.SetHidePatterns(cfg.HidePatterns)
+	filetreeModel.SetJournal(cfg.Journal)
+
+	if cfg.Views != nil {
+		filetreeModel.SetViewStore(cfg.Views)
+	}
+
+	return filetreeModel
+}
+
+// setSizeCmd lays out the panes for the size of the terminal.
+func (m *model) setSizeCmd(width, height int) tea.Cmd {
+	var cmds []tea.Cmd
+
+	m.width = width
+	m.height = height
+
+	filetreeWidth := width / 2
+	previewWidth := width - filetreeWidth
+	paneHeight := height - statusbar.Height
+
+	if len(m.tabs) > 1 {
+		paneHeight -= tabBarHeight
+	}
+
+	if m.config.Layout == MillerLayout {
+		parentWidth := width / 5
+		filetreeWidth = width * 2 / 5
+		previewWidth = width - parentWidth - filetreeWidth
+
+		m.parentFiletree.SetSize(parentWidth, paneHeight-3)
+		m.previewFiletree.SetSize(previewWidth, paneHeight-3)
+	}
+
+	cmds = append(cmds, m.image.SetSizeCmd(previewWidth, paneHeight))
+	cmds = append(cmds, m.markdown.SetSizeCmd(previewWidth, paneHeight))
+	cmds = append(cmds, m.csv.SetSizeCmd(previewWidth, paneHeight))
+
+	m.filetree.SetSize(filetreeWidth, paneHeight-3)
+	m.secondaryFiletree.SetSize(previewWidth, paneHeight-3)
+	m.code.SetSize(previewWidth, paneHeight)
+	m.pdf.SetSize(previewWidth, paneHeight)
+	m.statusbar.SetSize(width)
+	m.help.SetSize(previewWidth, paneHeight)
+	m.finder.SetSize(previewWidth, paneHeight)
+	m.historyPicker.SetSize(previewWidth, paneHeight)
+	m.bookmarksPicker.SetSize(previewWidth, paneHeight)
+	m.trashPicker.SetSize(previewWidth, paneHeight)
+	m.previewWidth = previewWidth
+	m.paneHeight = paneHeight
+
+	for i := range m.tabs {
+		if i != m.activeTab {
+			m.tabs[i].filetree.SetSize(filetreeWidth, paneHeight-3)
+		}
+	}
+
+	return tea.Batch(cmds...)
+}
+
+// saveActiveTab stores the state of the active tab so another can be shown.
+func (m *model) saveActiveTab() {
+	m.filetree.SetDisabled(true)
+
+	m.tabs[m.activeTab] = tab{
+		filetree:    m.filetree,
+		state:       m.state,
+		previewItem: m.previewItem,
+	}
+}
+
+// loadTabCmd makes the tab at the given index the active one, restoring the
+// preview it was showing.
+func (m *model) loadTabCmd(index int) tea.Cmd {
+	activeTab := m.tabs[index]
+
+	m.activeTab = index
+	m.filetree = activeTab.filetree
+	m.filetree.SetDisabled(false)
+	m.state = idleState
+	m.previewItem = filetree.DirectoryItem{}
+	m.previewPath = ""
+	m.parentDirectory = ""
+	m.disableAllViewports()
+	m.resetViewports()
+
+	switch activeTab.state {
+	case showCodeState, showImageState, showMarkdownState, showPdfState, showCsvState:
+		return m.previewFileCmd(activeTab.previewItem)
+	}
+
+	return nil
+}
+
+// switchTabCmd shows the tab at the given index.
+func (m *model) switchTabCmd(index int) tea.Cmd {
+	if index < 0 || index >= len(m.tabs) || index == m.activeTab {
+		return nil
+	}
+
+	m.saveActiveTab()
+
+	return m.loadTabCmd(index)
+}
+
+// newTabCmd opens a new tab in the current directory.
+func (m *model) newTabCmd() tea.Cmd {
+	m.saveActiveTab()
+
+	m.tabs = append(m.tabs, tab{
+		filetree: newFiletree(m.config, m.filetree.CurrentDirectory),
+	})
+
+	return tea.Batch(
+		m.loadTabCmd(len(m.tabs)-1),
+		m.setSizeCmd(m.width, m.height),
+		m.filetree.Init(),
+	)
+}
+
+// closeTabCmd closes the active tab, the last tab is never closed.
+func (m *model) closeTabCmd() tea.Cmd {
+	if len(m.tabs) == 1 {
+		return nil
+	}
+
+	m.filetree.Close()
+	m.tabs = append(m.tabs[:m.activeTab], m.tabs[m.activeTab+1:]...)
+
+	return tea.Batch(
+		m.loadTabCmd(min(m.activeTab, len(m.tabs)-1)),
+		m.setSizeCmd(m.width, m.height),
+	)
+}
+
+// tabBarView renders the names of the open tabs, or nothing when there is
+// only one.
+func (m model) tabBarView() string {
+	if len(m.tabs) < 2 {
+		return ""
+	}
+
+	var tabBar strings.Builder
+
+	for i, t := range m.tabs {
+		directory := t.filetree.CurrentDirectory
+		style := lipgloss.NewStyle().
+			Padding(0, 1).
+			Foreground(m.config.Theme.UnselectedTreeItemColor)
+
+		if i == m.activeTab {
+			directory = m.filetree.CurrentDirectory
+			style = style.
+				Bold(true).
+				Background(m.config.Theme.TitleBackgroundColor).
+				Foreground(m.config.Theme.TitleForegroundColor)
+		}
+
+		tabBar.WriteString(style.Render(fmt.Sprintf("%d %s", i+1, filepath.Base(directory))))
+	}
+
+	return lipgloss.NewStyle().
+		MaxWidth(m.width).
+		Render(tabBar.String())
+}
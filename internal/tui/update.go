@@ -1,11 +1,22 @@
 package tui
 
 import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 
+	"github.com/mistakenelf/fm/filesystem"
 	"github.com/mistakenelf/fm/filetree"
-	"github.com/mistakenelf/fm/statusbar"
+	"github.com/mistakenelf/fm/internal/bookmarks"
+	"github.com/mistakenelf/fm/internal/trash"
+	"github.com/mistakenelf/fm/picker"
+	"github.com/mistakenelf/fm/polish"
 )
 
 // Update handles all UI interactions.
@@ -20,23 +31,122 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.statusMessage = ""
 
 		return m, nil
-	case tea.WindowSizeMsg:
-		halfSize := msg.Width / 2
-		height := msg.Height - statusbar.Height
+	case errorMsg:
+		return m, m.newStatusMessageCmd(
+			lipgloss.NewStyle().
+				Foreground(polish.Colors.Red600).
+				Bold(true).
+				Render(string(msg)),
+		)
+	case bookmarkSavedMsg:
+		return m, m.newStatusMessageCmd(string(msg))
+	case bookmarksMsg:
+		items := make([]picker.Item, 0, len(msg))
+
+		for _, bookmark := range msg {
+			items = append(items, picker.Item{
+				Label:       bookmarks.Prefix + bookmark.Name,
+				Description: bookmark.Path,
+				Value:       bookmark.Name,
+			})
+		}
+
+		m.bookmarksPicker.SetItems(items)
+
+		return m, nil
+	case trashMsg:
+		items := make([]picker.Item, 0, len(msg))
+
+		for _, item := range msg {
+			items = append(items, picker.Item{
+				Label:       item.OriginalPath,
+				Description: item.DeletionDate.Format("2006-01-02 15:04"),
+				Value:       item.InfoPath,
+			})
+		}
 
-		cmds = append(cmds, m.image.SetSizeCmd(halfSize, height))
-		cmds = append(cmds, m.markdown.SetSizeCmd(halfSize, height))
-		cmds = append(cmds, m.csv.SetSizeCmd(halfSize, height))
+		m.trashPicker.SetItems(items)
+
+		return m, nil
+	case jumpToDirectoryMsg:
+		return m, m.filetree.GetDirectoryListingCmd(string(msg))
+	case gitDiffMsg:
+		if msg == "" {
+			return m, m.newStatusMessageCmd("No changes since the last commit")
+		}
 
-		m.filetree.SetSize(halfSize, height-3)
-		m.secondaryFiletree.SetSize(halfSize, height-3)
-		m.code.SetSize(halfSize, height)
-		m.pdf.SetSize(halfSize, height)
-		m.statusbar.SetSize(msg.Width)
-		m.help.SetSize(halfSize, height)
+		return m, m.showGitOutputCmd(string(msg), "diff")
+	case gitBlameMsg:
+		return m, m.showGitOutputCmd(string(msg), "")
+	case tea.WindowSizeMsg:
+		return m, m.setSizeCmd(msg.Width, msg.Height)
+	case confirmSizeMsg:
+		if m.confirmation != nil && m.confirmation.id == msg.id {
+			confirmation := *m.confirmation
+			confirmation.size = msg.size
+			confirmation.sizeKnown = true
+			m.confirmation = &confirmation
+		}
 
-		return m, tea.Batch(cmds...)
+		return m, nil
 	case tea.KeyMsg:
+		if m.confirmation != nil {
+			return m.updateConfirmation(msg)
+		}
+
+		if m.conflictPrompt != nil {
+			return m.updateConflictPrompt(msg)
+		}
+
+		if m.state == showFinderState {
+			return m.updateFinder(msg)
+		}
+
+		if m.state == showHistoryState {
+			return m.updateHistoryPicker(msg)
+		}
+
+		if m.state == showBookmarksState {
+			return m.updateBookmarksPicker(msg)
+		}
+
+		if m.state == showTrashState {
+			return m.updateTrashPicker(msg)
+		}
+
+		if m.pendingMark != noMarkAction {
+			return m.updatePendingMark(msg)
+		}
+
+		if m.pendingRegister {
+			return m.updatePendingRegister(msg)
+		}
+
+		if !m.confirmed {
+			if prompt, paths, ok := m.confirmationPrompt(msg); ok {
+				return m, m.confirmCmd(msg, prompt, paths)
+			}
+		}
+
+		if m.conflictResolutions == nil {
+			conflicts, err := m.findConflicts(msg)
+			if err != nil {
+				return m, func() tea.Msg {
+					return errorMsg(err.Error())
+				}
+			}
+
+			if len(conflicts) > 0 {
+				m.conflictPrompt = &conflictPrompt{
+					keyMsg:      msg,
+					conflicts:   conflicts,
+					resolutions: make(map[string]filesystem.ConflictResolution),
+				}
+
+				return m, nil
+			}
+		}
+
 		switch {
 		case key.Matches(msg, m.keyMap.ForceQuit):
 			return m, tea.Quit
@@ -54,12 +164,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 			m.state = idleState
+			m.previewPath = ""
 			m.showTextInput = false
 			m.disableAllViewports()
 			m.resetViewports()
 			m.filetree.SetDisabled(false)
 			m.textinput.Blur()
 			m.filetree.State = filetree.IdleState
+			m.filetree.ClearFilter()
 			m.secondaryFiletree.SetDisabled(true)
 			m.activePane = 0
 
@@ -77,6 +189,99 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.secondaryFiletree.SetDisabled(false)
 				cmds = append(cmds, m.secondaryFiletree.GetDirectoryListingCmd(m.filetree.CurrentDirectory))
 			}
+		case key.Matches(msg, m.keyMap.FuzzyFind):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				m.state = showFinderState
+				m.disableAllViewports()
+				m.filetree.SetDisabled(true)
+				m.finder.HideIgnored = m.filetree.GetHideIgnored()
+
+				return m, m.finder.StartCmd(m.filetree.CurrentDirectory)
+			}
+		case key.Matches(msg, m.keyMap.ShowHistory):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				m.showHistoryPicker()
+
+				return m, nil
+			}
+		case key.Matches(msg, m.keyMap.ShowTrash):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				m.state = showTrashState
+				m.disableAllViewports()
+				m.filetree.SetDisabled(true)
+
+				return m, loadTrashCmd()
+			}
+		case key.Matches(msg, m.keyMap.ShowBookmarks):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				m.state = showBookmarksState
+				m.disableAllViewports()
+				m.filetree.SetDisabled(true)
+
+				return m, loadBookmarksCmd()
+			}
+		case key.Matches(msg, m.keyMap.SetMark):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				m.pendingMark = setMarkAction
+
+				return m, nil
+			}
+		case key.Matches(msg, m.keyMap.JumpToMark):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				m.pendingMark = jumpToMarkAction
+
+				return m, nil
+			}
+		case key.Matches(msg, m.keyMap.SelectRegister):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				m.pendingRegister = true
+
+				return m, nil
+			}
+		case key.Matches(msg, m.keyMap.Yank), key.Matches(msg, m.keyMap.Cut):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				return m, m.storeRegisterCmd(key.Matches(msg, m.keyMap.Cut))
+			}
+		case key.Matches(msg, m.keyMap.Paste):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				return m, m.pasteCmd()
+			}
+		case key.Matches(msg, m.keyMap.UnzipDirectoryItem):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				return m, m.filetree.UnzipSelectedItemsCmd(m.conflictResolver())
+			}
+		case key.Matches(msg, m.keyMap.NewTab):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				return m, m.newTabCmd()
+			}
+		case key.Matches(msg, m.keyMap.CloseTab):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				return m, m.closeTabCmd()
+			}
+		case key.Matches(msg, m.keyMap.NextTab):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				return m, m.switchTabCmd((m.activeTab + 1) % len(m.tabs))
+			}
+		case key.Matches(msg, m.keyMap.PreviousTab):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				return m, m.switchTabCmd((m.activeTab + len(m.tabs) - 1) % len(m.tabs))
+			}
+		case key.Matches(msg, m.keyMap.JumpToTab):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				keyName := msg.String()
+
+				return m, m.switchTabCmd(int(keyName[len(keyName)-1] - '1'))
+			}
+		case key.Matches(msg, m.keyMap.GitDiff):
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
+				return m, gitDiffCmd(m.filetree.GetSelectedItem().Path)
+			}
+		case key.Matches(msg, m.keyMap.GitBlame):
+			selectedItem := m.filetree.GetSelectedItem()
+
+			if m.activePane == 0 && m.filetree.State == filetree.IdleState && !selectedItem.IsDirectory {
+				return m, gitBlameCmd(selectedItem.Path)
+			}
 		case key.Matches(msg, m.keyMap.ShowTextInput):
 			if m.activePane == 0 && m.filetree.State == filetree.IdleState {
 				m.showTextInput = true
@@ -97,18 +302,34 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case m.filetree.State == filetree.MoveState:
 				cmds = append(
 					cmds,
-					m.filetree.MoveDirectoryItemCmd(
-						m.filetree.GetSelectedItem().Path,
-						m.secondaryFiletree.CurrentDirectory+"/"+m.filetree.GetSelectedItem().Name,
+					m.filetree.MoveDirectoryItemsCmd(
+						m.filetree.GetSelectedItems(),
+						m.secondaryFiletree.CurrentDirectory,
+						m.conflictResolver(),
 					),
 				)
 			case m.filetree.State == filetree.RenameState:
-				cmds = append(cmds,
-					m.filetree.RenameDirectoryItemCmd(
-						m.filetree.GetSelectedItem().Path,
-						m.filetree.CurrentDirectory+"/"+m.textinput.Value(),
-					),
-				)
+				cmds = append(cmds, m.filetree.RenameSelectedItemCmd(m.textinput.Value(), m.conflictResolver()))
+			case m.filetree.State == filetree.CreateSymlinkState:
+				cmds = append(cmds, m.filetree.CreateSymlinksCmd(m.textinput.Value()))
+			case m.filetree.State == filetree.CreateHardlinkState:
+				cmds = append(cmds, m.filetree.CreateHardlinksCmd(m.textinput.Value()))
+			case m.filetree.State == filetree.MarkByGlobState:
+				cmds = append(cmds, m.filetree.MarkByGlobCmd(m.textinput.Value()))
+			case m.filetree.State == filetree.FilterState:
+				m.filetree.State = filetree.IdleState
+			case m.filetree.State == filetree.AddBookmarkState:
+				name := m.textinput.Value()
+
+				if strings.TrimSpace(name) == "" {
+					name = filepath.Base(m.filetree.CurrentDirectory)
+				}
+
+				cmds = append(cmds, setBookmarkCmd(name, m.filetree.CurrentDirectory))
+				m.filetree.State = filetree.IdleState
+			case m.filetree.State == filetree.JumpState:
+				cmds = append(cmds, jumpToFrecentCmd(m.textinput.Value(), m.filetree.CurrentDirectory))
+				m.filetree.State = filetree.IdleState
 			default:
 				return m, nil
 			}
@@ -118,6 +339,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.textinput.Reset()
 			m.showTextInput = false
 			m.activePane = 0
+		case key.Matches(msg, m.keyMap.CycleFilterMode):
+			if m.filetree.State == filetree.FilterState {
+				_ = m.filetree.CycleFilterMode()
+			}
 		case key.Matches(msg, m.keyMap.TogglePane):
 			if !m.showTextInput {
 				m.activePane = (m.activePane + 1) % 2
@@ -168,17 +393,45 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	if m.filetree.State == filetree.CreateDirectoryState ||
 		m.filetree.State == filetree.CreateFileState ||
-		m.filetree.State == filetree.RenameState {
+		m.filetree.State == filetree.RenameState ||
+		m.filetree.State == filetree.MarkByGlobState ||
+		m.filetree.State == filetree.FilterState ||
+		m.filetree.State == filetree.AddBookmarkState ||
+		m.filetree.State == filetree.JumpState ||
+		m.filetree.State == filetree.CreateSymlinkState ||
+		m.filetree.State == filetree.CreateHardlinkState {
 		m.textinput, cmd = m.textinput.Update(msg)
 		cmds = append(cmds, cmd)
 	}
 
+	if m.filetree.State == filetree.FilterState && m.textinput.Value() != m.filetree.GetFilter() {
+		_ = m.filetree.SetFilter(m.textinput.Value())
+	}
+
 	m.filetree, cmd = m.filetree.Update(msg)
 	cmds = append(cmds, cmd)
 
 	m.secondaryFiletree, cmd = m.secondaryFiletree.Update(msg)
 	cmds = append(cmds, cmd)
 
+	// Listings requested by tabs in the background still reach them.
+	for i := range m.tabs {
+		if i != m.activeTab {
+			m.tabs[i].filetree, cmd = m.tabs[i].filetree.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+	}
+
+	// The parent and preview columns of the miller layout only follow the
+	// primary filetree and never handle key presses themselves.
+	if _, ok := msg.(tea.KeyMsg); !ok {
+		m.parentFiletree, cmd = m.parentFiletree.Update(msg)
+		cmds = append(cmds, cmd)
+
+		m.previewFiletree, cmd = m.previewFiletree.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+
 	m.code, cmd = m.code.Update(msg)
 	cmds = append(cmds, cmd)
 
@@ -197,7 +450,211 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	m.csv, cmd = m.csv.Update(msg)
 	cmds = append(cmds, cmd)
 
+	m.finder, cmd = m.finder.Update(msg)
+	cmds = append(cmds, cmd)
+
+	cmds = append(cmds, m.syncMillerColumnsCmd())
+
+	if m.filetree.CurrentDirectory != m.visitedDirectory {
+		m.visitedDirectory = m.filetree.CurrentDirectory
+		cmds = append(cmds, recordVisitCmd(m.visitedDirectory))
+	}
+
 	m.updateStatusBar()
 
 	return m, tea.Batch(cmds...)
 }
+
+// updateFinder handles key presses while the fuzzy finder is open.
+func (m model) updateFinder(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch {
+	case key.Matches(msg, m.keyMap.ForceQuit):
+		return m, tea.Quit
+	case key.Matches(msg, m.keyMap.ResetState):
+		m.finder.Close()
+		m.state = idleState
+		m.filetree.SetDisabled(false)
+
+		return m, nil
+	case key.Matches(msg, m.keyMap.Submit):
+		selectedPath := m.finder.GetSelectedPath()
+
+		m.finder.Close()
+		m.state = idleState
+		m.filetree.SetDisabled(false)
+
+		if selectedPath == "" {
+			return m, nil
+		}
+
+		return m, m.filetree.SelectPathCmd(selectedPath)
+	}
+
+	m.finder, cmd = m.finder.Update(msg)
+
+	return m, cmd
+}
+
+// showHistoryPicker lists the directories visited by the filetree, most
+// recent first, with the cursor on the current directory.
+func (m *model) showHistoryPicker() {
+	history := m.filetree.GetHistory()
+	items := make([]picker.Item, 0, len(history))
+
+	for i := len(history) - 1; i >= 0; i-- {
+		description := ""
+
+		if i == m.filetree.GetHistoryIndex() {
+			description = "current"
+		}
+
+		items = append(items, picker.Item{
+			Label:       history[i],
+			Description: description,
+			Value:       strconv.Itoa(i),
+		})
+	}
+
+	m.historyPicker.SetItems(items)
+	m.historyPicker.SetCursor(len(history) - 1 - m.filetree.GetHistoryIndex())
+	m.state = showHistoryState
+	m.disableAllViewports()
+	m.filetree.SetDisabled(true)
+}
+
+// updateHistoryPicker handles key presses while the history popup is open.
+func (m model) updateHistoryPicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch {
+	case key.Matches(msg, m.keyMap.ForceQuit):
+		return m, tea.Quit
+	case key.Matches(msg, m.keyMap.ResetState), key.Matches(msg, m.keyMap.ShowHistory):
+		m.state = idleState
+		m.filetree.SetDisabled(false)
+
+		return m, nil
+	case key.Matches(msg, m.keyMap.Submit):
+		m.state = idleState
+		m.filetree.SetDisabled(false)
+
+		item, ok := m.historyPicker.GetSelectedItem()
+		if !ok {
+			return m, nil
+		}
+
+		index, err := strconv.Atoi(item.Value)
+		if err != nil {
+			return m, nil
+		}
+
+		return m, m.filetree.GoToHistoryCmd(index)
+	}
+
+	m.historyPicker, cmd = m.historyPicker.Update(msg)
+
+	return m, cmd
+}
+
+// updateBookmarksPicker handles key presses while the bookmarks list is open.
+func (m model) updateBookmarksPicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch {
+	case key.Matches(msg, m.keyMap.ForceQuit):
+		return m, tea.Quit
+	case key.Matches(msg, m.keyMap.ResetState), key.Matches(msg, m.keyMap.ShowBookmarks):
+		m.state = idleState
+		m.filetree.SetDisabled(false)
+
+		return m, nil
+	case key.Matches(msg, m.keyMap.DeleteBookmark):
+		if item, ok := m.bookmarksPicker.GetSelectedItem(); ok {
+			return m, deleteBookmarkCmd(item.Value)
+		}
+
+		return m, nil
+	case key.Matches(msg, m.keyMap.Submit):
+		m.state = idleState
+		m.filetree.SetDisabled(false)
+
+		item, ok := m.bookmarksPicker.GetSelectedItem()
+		if !ok {
+			return m, nil
+		}
+
+		return m, m.filetree.GetDirectoryListingCmd(item.Description)
+	}
+
+	m.bookmarksPicker, cmd = m.bookmarksPicker.Update(msg)
+
+	return m, cmd
+}
+
+// updateTrashPicker handles key presses while the trash is open, restoring
+// or purging the selected item.
+func (m model) updateTrashPicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch {
+	case key.Matches(msg, m.keyMap.ForceQuit):
+		return m, tea.Quit
+	case key.Matches(msg, m.keyMap.ResetState), key.Matches(msg, m.keyMap.ShowTrash):
+		m.state = idleState
+		m.filetree.SetDisabled(false)
+
+		return m, nil
+	case key.Matches(msg, m.keyMap.Submit):
+		if item, ok := m.trashPicker.GetSelectedItem(); ok {
+			return m, tea.Sequence(
+				trashActionCmd(item.Value, trash.Restore),
+				m.filetree.GetDirectoryListingCmd(m.filetree.CurrentDirectory),
+			)
+		}
+
+		return m, nil
+	case key.Matches(msg, m.keyMap.PermanentlyDelete):
+		pickerItem, ok := m.trashPicker.GetSelectedItem()
+		if !ok {
+			return m, nil
+		}
+
+		if m.requiresConfirmation(ConfirmDelete) && !m.confirmed {
+			item, err := trash.Read(pickerItem.Value)
+			if err != nil {
+				return m, func() tea.Msg { return errorMsg(err.Error()) }
+			}
+
+			prompt := fmt.Sprintf("Permanently delete %s from the trash? This can't be undone.", filepath.Base(item.OriginalPath))
+
+			return m, m.confirmCmd(msg, prompt, []string{item.Path})
+		}
+
+		return m, trashActionCmd(pickerItem.Value, trash.Purge)
+	}
+
+	m.trashPicker, cmd = m.trashPicker.Update(msg)
+
+	return m, cmd
+}
+
+// updatePendingMark sets or jumps to the mark named by the letter pressed
+// after the set mark or jump to mark keys. Any other key cancels it.
+func (m model) updatePendingMark(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	action := m.pendingMark
+	m.pendingMark = noMarkAction
+
+	if msg.Type != tea.KeyRunes || len(msg.Runes) != 1 || !unicode.IsLetter(msg.Runes[0]) {
+		return m, nil
+	}
+
+	name := string(msg.Runes)
+
+	if action == setMarkAction {
+		return m, setBookmarkCmd(name, m.filetree.CurrentDirectory)
+	}
+
+	return m, jumpToBookmarkCmd(name)
+}
@@ -24,12 +24,44 @@ func (m model) View() string {
 		rightBox = m.secondaryFiletree.View()
 	case showCsvState:
 		rightBox = m.csv.View()
+	case showFinderState:
+		rightBox = m.finder.View()
+	case showDirectoryPreviewState:
+		rightBox = m.previewFiletree.View()
+	case showHistoryState:
+		rightBox = m.historyPicker.View()
+	case showBookmarksState:
+		rightBox = m.bookmarksPicker.View()
+	case showTrashState:
+		rightBox = m.trashPicker.View()
+	}
+
+	if m.confirmation != nil {
+		rightBox = m.confirmationView(m.previewWidth, m.paneHeight)
+	}
+
+	if m.conflictPrompt != nil {
+		rightBox = m.conflictPromptView(m.previewWidth, m.paneHeight)
+	}
+
+	if m.config.Layout == MillerLayout {
+		leftBox = lipgloss.JoinHorizontal(lipgloss.Top, m.parentFiletree.View(), leftBox)
+	}
+
+	panes := lipgloss.NewStyle().Render(
+		lipgloss.JoinHorizontal(lipgloss.Top, leftBox, rightBox),
+	)
+
+	if len(m.tabs) > 1 {
+		return lipgloss.JoinVertical(lipgloss.Top,
+			m.tabBarView(),
+			panes,
+			m.statusbar.View(),
+		)
 	}
 
 	return lipgloss.JoinVertical(lipgloss.Top,
-		lipgloss.NewStyle().Render(
-			lipgloss.JoinHorizontal(lipgloss.Top, leftBox, rightBox),
-		),
+		panes,
 		m.statusbar.View(),
 	)
 }
@@ -0,0 +1,124 @@
+// Package views keeps the view settings of directories so that they can be
+// restored when a directory is visited again.
+package views
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/mistakenelf/fm/filesystem"
+)
+
+const fileName = "views.json"
+
+// Settings are the settings of how a directory is listed.
+type Settings struct {
+	ShowHidden          bool   `json:"show_hidden"`
+	HideIgnored         bool   `json:"hide_ignored"`
+	ShowDirectoriesOnly bool   `json:"show_directories_only"`
+	ShowFilesOnly       bool   `json:"show_files_only"`
+	SortOrder           int    `json:"sort_order"`
+	SortReversed        bool   `json:"sort_reversed"`
+	DirectoriesFirst    bool   `json:"directories_first"`
+	Filter              string `json:"filter,omitempty"`
+	FilterMode          int    `json:"filter_mode"`
+}
+
+// Store holds the settings of each directory read from a views file. It is
+// safe for concurrent use.
+type Store struct {
+	mu       sync.Mutex
+	path     string
+	settings map[string]Settings
+}
+
+// DefaultPath returns the path of the views file in the data directory.
+func DefaultPath() (string, error) {
+	dataDirectory, err := filesystem.GetDataDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dataDirectory, fileName), nil
+}
+
+// Load reads the default views file.
+func Load() (*Store, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadFile(path)
+}
+
+// LoadFile reads the views file provided. A file which does not exist yet
+// holds no settings.
+func LoadFile(path string) (*Store, error) {
+	store := &Store{
+		path:     path,
+		settings: make(map[string]Settings),
+	}
+
+	content, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, &store.settings); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	return store, nil
+}
+
+// Get returns the settings of a directory, reporting false when it has none.
+func (s *Store) Get(directory string) (Settings, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings, ok := s.settings[directory]
+
+	return settings, ok
+}
+
+// Set stores the settings of a directory. Settings equal to the defaults are
+// forgotten rather than stored. It reports whether anything changed.
+func (s *Store) Set(directory string, settings, defaults Settings) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.settings[directory]
+
+	if settings == defaults {
+		delete(s.settings, directory)
+
+		return ok
+	}
+
+	s.settings[directory] = settings
+
+	return !ok || current != settings
+}
+
+// Save writes the settings back to the file they were loaded from.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, err := json.Marshal(s.settings)
+	if err != nil {
+		return err
+	}
+
+	return filesystem.WriteFileAtomically(s.path, content)
+}
@@ -10,11 +10,14 @@ type KeyMap struct {
 	GoToHomeDirectory   key.Binding
 	GoToRootDirectory   key.Binding
 	ToggleHidden        key.Binding
+	ToggleIgnored       key.Binding
 	OpenDirectory       key.Binding
 	PreviousDirectory   key.Binding
 	CopyPathToClipboard key.Binding
 	CopyDirectoryItem   key.Binding
 	DeleteDirectoryItem key.Binding
+	PermanentlyDelete   key.Binding
+	ShowTrash           key.Binding
 	ZipDirectoryItem    key.Binding
 	UnzipDirectoryItem  key.Binding
 	ShowDirectoriesOnly key.Binding
@@ -34,6 +37,56 @@ type KeyMap struct {
 	GotoBottom          key.Binding
 	MoveDirectoryItem   key.Binding
 	RenameDirectoryItem key.Binding
+	ToggleMark          key.Binding
+	MarkAll             key.Binding
+	InvertMarks         key.Binding
+	ClearMarks          key.Binding
+	MarkByGlob          key.Binding
+	FuzzyFind           key.Binding
+	Filter              key.Binding
+	CycleFilterMode     key.Binding
+	CycleSortOrder      key.Binding
+	ReverseSort         key.Binding
+	DirectoriesFirst    key.Binding
+	ToggleDetails       key.Binding
+	ToggleTreeView      key.Binding
+	ToggleExpand        key.Binding
+	ExpandAll           key.Binding
+	CollapseAll         key.Binding
+	GoBack              key.Binding
+	GoForward           key.Binding
+	ShowHistory         key.Binding
+	AddBookmark         key.Binding
+	ShowBookmarks       key.Binding
+	DeleteBookmark      key.Binding
+	SetMark             key.Binding
+	JumpToMark          key.Binding
+	JumpToFrecent       key.Binding
+	NewTab              key.Binding
+	CloseTab            key.Binding
+	NextTab             key.Binding
+	PreviousTab         key.Binding
+	JumpToTab           key.Binding
+	GitStage            key.Binding
+	GitUnstage          key.Binding
+	GitDiscard          key.Binding
+	GitDiff             key.Binding
+	GitBlame            key.Binding
+	CreateSymlink       key.Binding
+	CreateHardlink      key.Binding
+	ToggleFollowLinks   key.Binding
+	Undo                key.Binding
+	Redo                key.Binding
+	Confirm             key.Binding
+	Yank                key.Binding
+	Cut                 key.Binding
+	Paste               key.Binding
+	SelectRegister      key.Binding
+	Overwrite           key.Binding
+	Skip                key.Binding
+	KeepBoth            key.Binding
+	OverwriteIfNewer    key.Binding
+	ApplyToAll          key.Binding
 }
 
 func DefaultKeyMap() KeyMap {
@@ -43,7 +96,7 @@ func DefaultKeyMap() KeyMap {
 		TogglePane:          key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "Toggle between l/r panes")),
 		OpenFile:            key.NewBinding(key.WithKeys("l", "right"), key.WithHelp("l", "Preview file")),
 		ResetState:          key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "Reset state")),
-		ShowTextInput:       key.NewBinding(key.WithKeys("N", "M", "R"), key.WithHelp("N, M", "Show text input to create file or directory")),
+		ShowTextInput:       key.NewBinding(key.WithKeys("N", "M", "R", "*", "f", "B", "z", "alt+l", "alt+h"), key.WithHelp("N, M", "Show text input to create file or directory")),
 		Submit:              key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "Submit text input value")),
 		GotoTop:             key.NewBinding(key.WithKeys("g"), key.WithHelp("g", "Go to top of pane")),
 		GotoBottom:          key.NewBinding(key.WithKeys("G"), key.WithHelp("G", "Go to bottom of pane")),
@@ -55,11 +108,14 @@ func DefaultKeyMap() KeyMap {
 		GoToHomeDirectory:   key.NewBinding(key.WithKeys("~"), key.WithHelp("~", "Go to home directory")),
 		GoToRootDirectory:   key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "Go to root directory")),
 		ToggleHidden:        key.NewBinding(key.WithKeys("."), key.WithHelp(".", "Toggle hidden files/folders")),
+		ToggleIgnored:       key.NewBinding(key.WithKeys("I"), key.WithHelp("I", "Toggle files matched by ignore files")),
 		OpenDirectory:       key.NewBinding(key.WithKeys("l", "right"), key.WithHelp("l", "Open directory")),
 		PreviousDirectory:   key.NewBinding(key.WithKeys("h", "left"), key.WithHelp("h", "Go to previous directory")),
 		CopyPathToClipboard: key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "Copy path to clipboard")),
 		CopyDirectoryItem:   key.NewBinding(key.WithKeys("C"), key.WithHelp("C", "Copy directory item")),
-		DeleteDirectoryItem: key.NewBinding(key.WithKeys("X"), key.WithHelp("X", "Delete directory item")),
+		DeleteDirectoryItem: key.NewBinding(key.WithKeys("X"), key.WithHelp("X", "Move directory item to the trash")),
+		PermanentlyDelete:   key.NewBinding(key.WithKeys("ctrl+x"), key.WithHelp("ctrl+x", "Permanently delete directory item")),
+		ShowTrash:           key.NewBinding(key.WithKeys("ctrl+t"), key.WithHelp("ctrl+t", "Show trash, enter restores and ctrl+x purges")),
 		ZipDirectoryItem:    key.NewBinding(key.WithKeys("Z"), key.WithHelp("Z", "Zip directory item")),
 		UnzipDirectoryItem:  key.NewBinding(key.WithKeys("U"), key.WithHelp("U", "Unzip directory item")),
 		ShowDirectoriesOnly: key.NewBinding(key.WithKeys("D"), key.WithHelp("D", "Show directories only")),
@@ -69,5 +125,73 @@ func DefaultKeyMap() KeyMap {
 		CreateFile:          key.NewBinding(key.WithKeys("N"), key.WithHelp("N", "Create new file")),
 		CreateDirectory:     key.NewBinding(key.WithKeys("M"), key.WithHelp("M", "Create new directory")),
 		RenameDirectoryItem: key.NewBinding(key.WithKeys("R"), key.WithHelp("R", "Rename directory items")),
+		ToggleMark:          key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "Toggle mark on directory item")),
+		MarkAll:             key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "Mark all directory items")),
+		InvertMarks:         key.NewBinding(key.WithKeys("v"), key.WithHelp("v", "Invert marked directory items")),
+		ClearMarks:          key.NewBinding(key.WithKeys("u"), key.WithHelp("u", "Clear marked directory items")),
+		MarkByGlob:          key.NewBinding(key.WithKeys("*"), key.WithHelp("*", "Mark directory items matching a glob")),
+		FuzzyFind:           key.NewBinding(key.WithKeys("ctrl+f"), key.WithHelp("ctrl+f", "Fuzzy find files recursively")),
+		Filter:              key.NewBinding(key.WithKeys("f"), key.WithHelp("f", "Filter current directory")),
+		CycleFilterMode:     key.NewBinding(key.WithKeys("ctrl+g"), key.WithHelp("ctrl+g", "Cycle filter mode (substring, glob, regex)")),
+		CycleSortOrder:      key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "Cycle sort order (name, size, modified, extension, type)")),
+		ReverseSort:         key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "Reverse sort order")),
+		DirectoriesFirst:    key.NewBinding(key.WithKeys("S"), key.WithHelp("S", "Toggle directories first")),
+		ToggleDetails:       key.NewBinding(key.WithKeys("L"), key.WithHelp("L", "Toggle detailed columns view")),
+		ToggleTreeView:      key.NewBinding(key.WithKeys("T"), key.WithHelp("T", "Toggle tree view")),
+		ToggleExpand:        key.NewBinding(key.WithKeys("o"), key.WithHelp("o", "Expand/collapse directory in tree view")),
+		ExpandAll:           key.NewBinding(key.WithKeys("+"), key.WithHelp("[n]+", "Expand all directories to depth n (default 1)")),
+		CollapseAll:         key.NewBinding(key.WithKeys("-"), key.WithHelp("[n]-", "Collapse all directories to depth n (default 0)")),
+		GoBack:              key.NewBinding(key.WithKeys("["), key.WithHelp("[", "Go back in directory history")),
+		GoForward:           key.NewBinding(key.WithKeys("]"), key.WithHelp("]", "Go forward in directory history")),
+		ShowHistory:         key.NewBinding(key.WithKeys("H"), key.WithHelp("H", "Show directory history")),
+		AddBookmark:         key.NewBinding(key.WithKeys("B"), key.WithHelp("B", "Bookmark current directory")),
+		ShowBookmarks:       key.NewBinding(key.WithKeys("`"), key.WithHelp("`", "Show bookmarks")),
+		DeleteBookmark:      key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "Delete bookmark from bookmarks list")),
+		SetMark:             key.NewBinding(key.WithKeys("b"), key.WithHelp("b<letter>", "Mark current directory with a letter")),
+		JumpToMark:          key.NewBinding(key.WithKeys("'"), key.WithHelp("'<letter>", "Jump to directory marked with a letter")),
+		JumpToFrecent:       key.NewBinding(key.WithKeys("z"), key.WithHelp("z", "Jump to frequently and recently visited directory")),
+		NewTab:              key.NewBinding(key.WithKeys("t"), key.WithHelp("t", "Open new tab")),
+		CloseTab:            key.NewBinding(key.WithKeys("ctrl+w"), key.WithHelp("ctrl+w", "Close tab")),
+		NextTab:             key.NewBinding(key.WithKeys("}"), key.WithHelp("}", "Go to next tab")),
+		PreviousTab:         key.NewBinding(key.WithKeys("{"), key.WithHelp("{", "Go to previous tab")),
+		JumpToTab: key.NewBinding(
+			key.WithKeys("alt+1", "alt+2", "alt+3", "alt+4", "alt+5", "alt+6", "alt+7", "alt+8", "alt+9"),
+			key.WithHelp("alt+[1-9]", "Go to tab by number"),
+		),
+		GitStage:   key.NewBinding(key.WithKeys("alt+s"), key.WithHelp("alt+s", "Stage changes")),
+		GitUnstage: key.NewBinding(key.WithKeys("alt+u"), key.WithHelp("alt+u", "Unstage changes")),
+		GitDiscard: key.NewBinding(key.WithKeys("alt+x"), key.WithHelp("alt+x", "Discard unstaged changes")),
+		GitDiff:    key.NewBinding(key.WithKeys("alt+d"), key.WithHelp("alt+d", "Show git diff")),
+		GitBlame:   key.NewBinding(key.WithKeys("alt+b"), key.WithHelp("alt+b", "Show git blame")),
+		CreateSymlink: key.NewBinding(
+			key.WithKeys("alt+l"),
+			key.WithHelp("alt+l", "Create symlinks to the selection"),
+		),
+		CreateHardlink: key.NewBinding(
+			key.WithKeys("alt+h"),
+			key.WithHelp("alt+h", "Create hard links to the selection"),
+		),
+		ToggleFollowLinks: key.NewBinding(
+			key.WithKeys("@"),
+			key.WithHelp("@", "Toggle whether operations follow symlinks"),
+		),
+		Undo: key.NewBinding(key.WithKeys("ctrl+z"), key.WithHelp("ctrl+z", "Undo the last file operation")),
+		Redo: key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl+r", "Redo the last undone file operation")),
+		Confirm: key.NewBinding(
+			key.WithKeys("y"),
+			key.WithHelp("y", "Confirm deleting, overwriting or acting on several items"),
+		),
+		Yank:  key.NewBinding(key.WithKeys("Y"), key.WithHelp("Y", "Yank the selection into a register")),
+		Cut:   key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "Cut the selection into a register")),
+		Paste: key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "Paste a register into the current directory")),
+		SelectRegister: key.NewBinding(
+			key.WithKeys(`"`),
+			key.WithHelp(`"<letter>`, "Use the named register for the next yank, cut or paste"),
+		),
+		Overwrite:        key.NewBinding(key.WithKeys("o"), key.WithHelp("o", "Overwrite the existing item on a conflict")),
+		Skip:             key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "Skip the item on a conflict")),
+		KeepBoth:         key.NewBinding(key.WithKeys("k"), key.WithHelp("k", "Keep both items on a conflict")),
+		OverwriteIfNewer: key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "Overwrite on a conflict if the item is newer")),
+		ApplyToAll:       key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "Apply the conflict choice to the remaining conflicts")),
 	}
 }
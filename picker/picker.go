@@ -0,0 +1,168 @@
+// Package picker implements a picker bubble which lets the user choose
+// a single item from a list.
+package picker
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/exp/term/ansi"
+)
+
+type TitleColor struct {
+	Background lipgloss.AdaptiveColor
+	Foreground lipgloss.AdaptiveColor
+}
+
+// Item represents a single choice in the picker.
+type Item struct {
+	Label       string
+	Description string
+	Value       string
+}
+
+// Model represents the properties of a picker bubble.
+type Model struct {
+	Items               []Item
+	Cursor              int
+	Title               string
+	TitleColor          TitleColor
+	EmptyMessage        string
+	width               int
+	height              int
+	selectedItemColor   lipgloss.AdaptiveColor
+	unselectedItemColor lipgloss.AdaptiveColor
+	inactiveItemColor   lipgloss.AdaptiveColor
+}
+
+// New creates a new instance of a picker bubble.
+func New(title string, titleColor TitleColor) Model {
+	return Model{
+		Title:               title,
+		TitleColor:          titleColor,
+		EmptyMessage:        "Nothing to show",
+		selectedItemColor:   lipgloss.AdaptiveColor{Light: "212", Dark: "212"},
+		unselectedItemColor: lipgloss.AdaptiveColor{Light: "#000000", Dark: "#ffffff"},
+		inactiveItemColor:   lipgloss.AdaptiveColor{Light: "243", Dark: "243"},
+	}
+}
+
+// SetItems replaces the items of the picker keeping the cursor in range.
+func (m *Model) SetItems(items []Item) {
+	m.Items = items
+	m.SetCursor(m.Cursor)
+}
+
+// SetCursor moves the cursor onto the item at the given index.
+func (m *Model) SetCursor(index int) {
+	m.Cursor = max(min(index, len(m.Items)-1), 0)
+}
+
+// GetSelectedItem returns the item under the cursor and whether there is one.
+func (m Model) GetSelectedItem() (Item, bool) {
+	if len(m.Items) == 0 {
+		return Item{}, false
+	}
+
+	return m.Items[m.Cursor], true
+}
+
+// SetSize sets the size of the picker.
+func (m *Model) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+}
+
+// SetTheme sets the colors of the items.
+func (m *Model) SetTheme(selectedItemColor, unselectedItemColor lipgloss.AdaptiveColor) {
+	m.selectedItemColor = selectedItemColor
+	m.unselectedItemColor = unselectedItemColor
+}
+
+// Update handles UI interactions with the picker bubble.
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "up", "k", "ctrl+p":
+			m.SetCursor(m.Cursor - 1)
+		case "down", "j", "ctrl+n":
+			m.SetCursor(m.Cursor + 1)
+		case "g", "home":
+			m.SetCursor(0)
+		case "G", "end":
+			m.SetCursor(len(m.Items) - 1)
+		}
+	}
+
+	return m, nil
+}
+
+// View returns a string representation of the picker bubble.
+func (m Model) View() string {
+	var itemList strings.Builder
+
+	titleText := lipgloss.NewStyle().Bold(true).
+		Background(m.TitleColor.Background).
+		Foreground(m.TitleColor.Foreground).
+		Padding(0, 1).
+		Italic(true).
+		Render(m.Title)
+
+	labelWidth := 0
+	for _, item := range m.Items {
+		labelWidth = max(labelWidth, ansi.StringWidth(item.Label))
+	}
+
+	labelWidth = min(labelWidth, m.width*2/3)
+
+	visibleRows := max(m.height-2, 0)
+	start := max(m.Cursor-visibleRows+1, 0)
+
+	for i := start; i < len(m.Items) && i < start+visibleRows; i++ {
+		item := m.Items[i]
+		textColor := m.unselectedItemColor
+
+		if i == m.Cursor {
+			textColor = m.selectedItemColor
+		}
+
+		label := ansi.Truncate(item.Label, labelWidth, "…")
+		label += strings.Repeat(" ", max(labelWidth-ansi.StringWidth(label), 0))
+
+		itemList.WriteString(
+			lipgloss.NewStyle().
+				Bold(i == m.Cursor).
+				Foreground(textColor).
+				Render(label),
+		)
+
+		if item.Description != "" {
+			itemList.WriteString(
+				lipgloss.NewStyle().
+					Foreground(m.inactiveItemColor).
+					Render("  " + item.Description),
+			)
+		}
+
+		itemList.WriteString("\n")
+	}
+
+	if len(m.Items) == 0 {
+		itemList.WriteString(
+			lipgloss.NewStyle().
+				Foreground(m.inactiveItemColor).
+				Render(m.EmptyMessage),
+		)
+	}
+
+	return lipgloss.NewStyle().
+		Width(m.width).
+		Height(m.height).
+		MaxWidth(m.width).
+		Render(lipgloss.JoinVertical(
+			lipgloss.Top,
+			titleText,
+			itemList.String(),
+		))
+}
@@ -5,11 +5,15 @@ import "github.com/charmbracelet/lipgloss"
 type ColorMap struct {
 	Red600    lipgloss.Color
 	Yellow500 lipgloss.Color
+	Green600  lipgloss.Color
+	Blue500   lipgloss.Color
 }
 
 var Colors = ColorMap{
 	Red600:    lipgloss.Color("#dc2626"),
 	Yellow500: lipgloss.Color("#eab308"),
+	Green600:  lipgloss.Color("#16a34a"),
+	Blue500:   lipgloss.Color("#3b82f6"),
 }
 
 type AdaptiveColorMap struct {